@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hasura/ddn-assets/internal/asset"
+	"github.com/spf13/cobra"
+)
+
+var diffFormat string
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "output format: text, json or markdown")
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old index.json or outputs folder> <new index.json or outputs folder>",
+	Short: "Report the connectors and versions that changed between two index.json files",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		oldIndex, err := asset.ReadIndexJSON(args[0])
+		if err != nil {
+			logger.Error("error reading the old index.json", "error", err)
+			os.Exit(1)
+			return
+		}
+		newIndex, err := asset.ReadIndexJSON(args[1])
+		if err != nil {
+			logger.Error("error reading the new index.json", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		diff := asset.DiffIndexes(oldIndex, newIndex)
+		switch diffFormat {
+		case "text":
+			if diff.Empty() {
+				fmt.Println("no connector changes")
+				return
+			}
+			fmt.Print(diff)
+		case "json":
+			diffJson, err := json.MarshalIndent(diff, "", "  ")
+			if err != nil {
+				logger.Error("error while marshalling the diff", "error", err)
+				os.Exit(1)
+				return
+			}
+			fmt.Println(string(diffJson))
+		case "markdown":
+			fmt.Print(diff.Markdown())
+		default:
+			logger.Error("unknown format, expected text, json or markdown", "format", diffFormat)
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hasura/ddn-assets/internal/asset"
+	"github.com/spf13/cobra"
+)
+
+var diffRemoteCmd = &cobra.Command{
+	Use:   "diff-remote <base url> [outputs folder]",
+	Short: "Compare a local generation with the outputs published at a base url",
+	Long:  "Compare the index.json and SHA256SUMS of a local outputs folder with the ones published at a base url, such as the data server, and report added, removed and changed connector versions",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputsFolder := asset.OutputFolderPath
+		if len(args) > 1 {
+			outputsFolder = args[1]
+		}
+
+		local, err := asset.ReadLocalOutputs(outputsFolder)
+		if err != nil {
+			logger.Error("error reading the local outputs", "error", err)
+			os.Exit(1)
+			return
+		}
+		remote, err := asset.FetchRemoteOutputs(&asset.Run{Fetchers: newFetchers(false, nil), Logger: logger}, args[0])
+		if err != nil {
+			logger.Error("error fetching the published outputs", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		report := asset.CompareOutputs(local, remote)
+		if !report.OK() {
+			fmt.Print(report)
+			logger.Error("published outputs differ from the local generation", "base_url", args[0])
+			os.Exit(1)
+			return
+		}
+		if report.ChecksumsUnavailable {
+			fmt.Print(report)
+			fmt.Println("published connector versions match the local generation")
+			return
+		}
+		fmt.Println("published outputs match the local generation")
+	},
+}
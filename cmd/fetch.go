@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/hasura/ddn-assets/internal/asset"
+	"github.com/spf13/cobra"
+)
+
+// fetchOpts are the flags of the fetch command.
+var fetchOpts downloadOptions
+
+func init() {
+	opts := &fetchOpts
+	fetchCmd.Flags().StringVar(&opts.cacheDir, "cache-dir", asset.DefaultCacheDir(), "content-addressed download cache to populate")
+	fetchCmd.Flags().StringVar(&opts.downloadPolicy, "download-policy", "", "YAML file restricting the schemes and hosts artefacts are downloaded from")
+	fetchCmd.Flags().BoolVar(&opts.allowFileURIs, "allow-file-uris", false, "allow file:// uris, which read the local disk, for a trusted registry of local builds")
+	fetchCmd.Flags().StringVar(&opts.signaturePolicy, "signature-policy", "", "YAML file configuring the keys connector tarballs are verified with, per namespace, to fetch their signatures")
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Populate the download cache for an offline generate",
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := &fetchOpts
+		run := &asset.Run{Logger: logger}
+
+		ndcHubGitRepoFilePath := os.Getenv("NDC_HUB_GIT_REPO_FILE_PATH")
+		if ndcHubGitRepoFilePath == "" {
+			logger.Error("please set a value for NDC_HUB_GIT_REPO_FILE_PATH env var")
+			os.Exit(1)
+			return
+		}
+
+		if opts.cacheDir == "" {
+			logger.Error("please set a value for --cache-dir")
+			os.Exit(1)
+			return
+		}
+		if err := setupDownloadCache(run, opts); err != nil {
+			logger.Error("error creating the download cache", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		if err := setupDownloadPolicy(run, opts); err != nil {
+			logger.Error("error loading the download policy", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		err := asset.CreateAssetFolders()
+		if err != nil {
+			logger.Error("error creating asset folders", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		sigPolicy, err := loadSignaturePolicy(opts.signaturePolicy)
+		if err != nil {
+			logger.Error("error loading the signature policy", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		_, connectorPackaging, err := readRegistry(ndcHubGitRepoFilePath)
+		if err != nil {
+			logger.Error("error reading the registry", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		if err = asset.FetchArtefacts(run, asset.ConnectorTarballArtefacts(connectorPackaging, sigPolicy)); err != nil {
+			logger.Error("error fetching connector tarballs", "error", err)
+			os.Exit(1)
+		}
+
+		// the cli plugin files are only known from the connector definitions
+		if err = asset.DownloadConnectorTarballs(run, connectorPackaging); err != nil {
+			logger.Error("error downloading connector tarball", "error", err)
+			os.Exit(1)
+		}
+		if err = asset.ExtractConnectorTarballs(run, connectorPackaging); err != nil {
+			logger.Error("error extracting connector tarballs", "error", err)
+			os.Exit(1)
+		}
+
+		artefacts, err := asset.CLIPluginArtefacts(connectorPackaging, false)
+		if err != nil {
+			logger.Error("error listing cli plugin files", "error", err)
+			os.Exit(1)
+		}
+		if err = asset.FetchArtefacts(run, artefacts); err != nil {
+			logger.Error("error fetching cli plugin files", "error", err)
+			os.Exit(1)
+		}
+	},
+}
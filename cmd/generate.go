@@ -1,142 +1,593 @@
 package cmd
 
 import (
+	"crypto"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hasura/ddn-assets/internal/asset"
+	"github.com/hasura/ddn-assets/internal/fetch"
 	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/signature"
+	"github.com/hasura/ddn-assets/internal/tracing"
+	"github.com/hasura/ddn-assets/internal/version"
 	"github.com/spf13/cobra"
 )
 
+// downloadOptions are the flags of the commands downloading artefacts.
+type downloadOptions struct {
+	cacheDir        string
+	cacheMaxSizeMB  int64
+	downloadPolicy  string
+	allowFileURIs   bool
+	signaturePolicy string
+}
+
+// generateOptions are the flags of the generate command.
+type generateOptions struct {
+	downloadOptions
+	extractCLIPlugins bool
+	offline           bool
+	mirrorDir         string
+	signingKey        string
+	jsonManifest      bool
+	extractLimits     asset.ExtractLimits
+	prune             bool
+	versionStatus     string
+	progressMode      string
+	metricsFile       string
+	metricsPushURL    string
+	otlpEndpoint      string
+	traceFile         string
+	keepGoing         bool
+}
+
+var generateOpts = generateOptions{extractLimits: asset.DefaultExtractLimits}
+
+func init() {
+	opts := &generateOpts
+	generateCmd.Flags().BoolVar(&opts.extractCLIPlugins, "extract-cli-plugins", false, "publish the cli plugin binaries unpacked from their archives instead of the archives")
+	generateCmd.Flags().StringVar(&opts.cacheDir, "cache-dir", asset.DefaultCacheDir(), "content-addressed download cache shared across runs, set to empty to disable")
+	generateCmd.Flags().Int64Var(&opts.cacheMaxSizeMB, "cache-max-size", 0, "size in MB the download cache is trimmed to after a run, least recently used first (0 for unlimited)")
+	generateCmd.Flags().BoolVar(&opts.offline, "offline", false, "resolve every artefact from the download cache or the mirror directory, without network access")
+	generateCmd.Flags().StringVar(&opts.mirrorDir, "mirror-dir", "", "pre-populated directory holding artefacts at <host>/<path>, used in offline mode")
+	generateCmd.Flags().StringVar(&opts.signingKey, "signing-key", "", "PEM private key file the outputs are signed with, defaults to the key in the DDN_ASSETS_SIGNING_KEY env var")
+	generateCmd.Flags().BoolVar(&opts.jsonManifest, "json-manifest", false, "write a manifest.json listing the outputs next to SHA256SUMS")
+	generateCmd.Flags().StringVar(&opts.downloadPolicy, "download-policy", "", "YAML file restricting the schemes and hosts artefacts are downloaded from")
+	generateCmd.Flags().BoolVar(&opts.allowFileURIs, "allow-file-uris", false, "allow file:// uris, which read the local disk, for a trusted registry of local builds")
+	generateCmd.Flags().Int64Var(&opts.extractLimits.MaxTotalSize, "extract-max-total-size", opts.extractLimits.MaxTotalSize, "maximum uncompressed size in bytes of a connector tarball (0 for unlimited)")
+	generateCmd.Flags().Int64Var(&opts.extractLimits.MaxFileSize, "extract-max-file-size", opts.extractLimits.MaxFileSize, "maximum size in bytes of a file in a connector tarball (0 for unlimited)")
+	generateCmd.Flags().IntVar(&opts.extractLimits.MaxEntries, "extract-max-entries", opts.extractLimits.MaxEntries, "maximum number of entries in a connector tarball (0 for unlimited)")
+	generateCmd.Flags().IntVar(&opts.extractLimits.MaxPathDepth, "extract-max-path-depth", opts.extractLimits.MaxPathDepth, "maximum path depth of an entry in a connector tarball (0 for unlimited)")
+	generateCmd.Flags().StringVar(&opts.signaturePolicy, "signature-policy", "", "YAML file configuring the keys connector tarballs are verified with, per namespace")
+	generateCmd.Flags().StringVar(&opts.versionStatus, "version-status", "", "YAML file marking connector versions as deprecated or yanked, overriding their connector-packaging.json")
+	generateCmd.Flags().StringVar(&opts.progressMode, "progress", "auto", "progress display: tty, log, none, or auto for tty on an interactive terminal and log otherwise, tty only logs warnings and errors while a stage is drawn")
+	generateCmd.Flags().StringVar(&opts.metricsFile, "metrics-file", "", "write the metrics of the run to this .prom file for the node_exporter textfile collector")
+	generateCmd.Flags().StringVar(&opts.metricsPushURL, "metrics-push-url", "", "push the metrics of the run to the Prometheus Pushgateway at this URL")
+	generateCmd.Flags().StringVar(&opts.otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint the spans of the run are exported to, defaults to the OTEL_EXPORTER_OTLP_ENDPOINT env var (OTEL_EXPORTER_OTLP_HEADERS and OTEL_EXPORTER_OTLP_TIMEOUT apply too)")
+	generateCmd.Flags().StringVar(&opts.traceFile, "trace-file", "", "write the spans of the run as OTLP JSON to this file, - for stdout")
+	generateCmd.Flags().BoolVar(&opts.keepGoing, "keep-going", false, "process every connector version independently, leaving the failed ones out of the index and summarising their failures")
+	generateCmd.Flags().BoolVar(&opts.prune, "prune", false, "remove downloaded, extracted and output versions that are no longer in ndc-hub before generating")
+}
+
+// generation is a generate run, with the metrics and the trace file it emits
+// as it exits.
+type generation struct {
+	opts         *generateOptions
+	run          *asset.Run
+	metrics      *asset.Metrics
+	traceFileOut *os.File
+}
+
 var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generate assets",
 	Run: func(cmd *cobra.Command, args []string) {
+		opts := &generateOpts
+		run := &asset.Run{
+			Offline:       opts.offline,
+			MirrorDir:     opts.mirrorDir,
+			ExtractLimits: &opts.extractLimits,
+			Logger:        logger,
+		}
+		if opts.keepGoing {
+			run.KeepGoing = &asset.Failures{}
+		}
+		g := &generation{opts: opts, run: run}
+
 		ndcHubGitRepoFilePath := os.Getenv("NDC_HUB_GIT_REPO_FILE_PATH")
 		if ndcHubGitRepoFilePath == "" {
-			fmt.Println("please set a value for NDC_HUB_GIT_REPO_FILE_PATH env var")
-			os.Exit(1)
+			logger.Error("please set a value for NDC_HUB_GIT_REPO_FILE_PATH env var")
+			g.exit()
 			return
 		}
 
 		dataServerURLString := os.Getenv("CONN_HUB_DATA_SERVER_URL")
 		if dataServerURLString == "" {
-			fmt.Println("please set a value for CONN_HUB_DATA_SERVER_URL env var")
-			os.Exit(1)
+			logger.Error("please set a value for CONN_HUB_DATA_SERVER_URL env var")
+			g.exit()
 			return
 		}
 		dataServerURL, err := url.Parse(dataServerURLString)
 		if err != nil {
-			fmt.Println("error parsing the data server URL from CONN_HUB_DATA_SERVER_URL env var", err)
-			os.Exit(1)
+			logger.Error("error parsing the data server URL from CONN_HUB_DATA_SERVER_URL env var", "error", err)
+			g.exit()
 			return
 		}
 
-		registryFolder := filepath.Join(ndcHubGitRepoFilePath, "registry")
-		_, err = os.Stat(registryFolder)
+		err = asset.CreateAssetFolders()
 		if err != nil {
-			fmt.Println("error while finding the registry folder", err)
-			os.Exit(1)
+			logger.Error("error creating asset folders", "error", err)
+			g.exit()
 			return
 		}
-		if os.IsNotExist(err) {
-			fmt.Println("registry folder does not exist")
-			os.Exit(1)
+
+		if err = setupDownloadCache(run, &opts.downloadOptions); err != nil {
+			logger.Error("error creating the download cache", "error", err)
+			g.exit()
+			return
+		}
+		if err = setupDownloadPolicy(run, &opts.downloadOptions); err != nil {
+			logger.Error("error loading the download policy", "error", err)
+			g.exit()
+			return
+		}
+		if err = setupProgress(run, opts.progressMode); err != nil {
+			logger.Error("error setting up the progress display", "error", err)
+			g.exit()
+			return
+		}
+		g.setupMetrics()
+		if err = g.setupTracing(); err != nil {
+			logger.Error("error setting up tracing", "error", err)
+			g.exit()
+			return
+		}
+		outputsKey, err := loadSigningKey(opts.signingKey)
+		if err != nil {
+			logger.Error("error loading the signing key", "error", err)
+			g.exit()
 			return
 		}
 
-		err = asset.CreateAssetFolders()
+		sigPolicy, err := loadSignaturePolicy(opts.signaturePolicy)
 		if err != nil {
-			fmt.Println("error creating asset folders", err)
-			os.Exit(1)
+			logger.Error("error loading the signature policy", "error", err)
+			g.exit()
 			return
 		}
 
-		var connectors []asset.Connector
-		var connectorPackaging []ndchub.ConnectorPackaging
-		err = filepath.WalkDir(registryFolder, func(path string, d fs.DirEntry, err error) error {
+		connectors, connectorPackaging, err := readRegistry(ndcHubGitRepoFilePath)
+		if err != nil {
+			logger.Error("error reading the registry", "error", err)
+			g.exit()
+			return
+		}
+		if g.metrics != nil {
+			g.metrics.SetCounts(len(connectors), len(connectorPackaging))
+		}
+
+		if opts.versionStatus != "" {
+			overrides, err := asset.LoadVersionStatusOverrides(opts.versionStatus)
 			if err != nil {
-				return err
+				logger.Error("error loading the version status overrides", "error", err)
+				g.exit()
+				return
 			}
-
-			if filepath.Base(path) == ndchub.MetadataJSON {
-				metadata, err := getConnectorMetadata(path)
-				if err != nil {
-					return err
-				}
-				if metadata != nil {
-					connectors = append(connectors, *metadata)
-				}
+			if err = asset.ApplyVersionStatusOverrides(connectorPackaging, overrides); err != nil {
+				logger.Error("error applying the version status overrides", "error", err)
+				g.exit()
+				return
 			}
+		}
+		versionStatuses, err := asset.VersionStatuses(connectorPackaging)
+		if err != nil {
+			logger.Error("error reading the version statuses", "error", err)
+			g.exit()
+			return
+		}
+		connectors = asset.ResolveLatestVersions(connectors, connectorPackaging)
 
-			if filepath.Base(path) == ndchub.ConnectorPackagingJSON {
-				cp, err := ndchub.GetConnectorPackaging(path)
-				if err != nil {
-					return err
-				}
-				if cp != nil {
-					connectorPackaging = append(connectorPackaging, *cp)
-				}
+		if opts.prune {
+			if err = pruneStaleVersions(connectorPackaging, false); err != nil {
+				logger.Error("error pruning stale versions", "error", err)
+				g.exit()
+				return
 			}
+		}
 
-			return nil
-		})
-		if err != nil {
-			fmt.Println("error while walking the registry folder", err)
-			os.Exit(1)
-			return
+		// in keep-going mode the versions with missing artefacts fail on their own
+		if opts.offline && !opts.keepGoing {
+			missing := asset.MissingOfflineArtefacts(run, asset.ConnectorTarballArtefacts(connectorPackaging, sigPolicy))
+			if len(missing) > 0 {
+				logger.Error("following connector tarballs are not available offline", "artefacts", asset.FormatArtefacts(missing))
+				g.exit()
+				return
+			}
 		}
 
 		connectorVersions := make(map[string][]string)
+		provenance := make(map[string]string)
 		for _, cp := range connectorPackaging {
 			slug := fmt.Sprintf("%s/%s", cp.Namespace, cp.Name)
 			connectorVersions[slug] = append(connectorVersions[slug], cp.Version)
+			provenance[fmt.Sprintf("%s/%s", slug, cp.Version)] = asset.ProvenanceIndexPath(cp.Namespace, cp.Name, cp.Version)
 		}
 
-		err = asset.WriteIndexJSON(&asset.Index{
+		index := &asset.Index{
 			TotalConnectors:   len(connectors),
 			Connectors:        connectors,
 			ConnectorVersions: connectorVersions,
-		})
-		if err != nil {
-			fmt.Println("error writing index.json", err)
-			os.Exit(1)
-			return
+			Provenance:        provenance,
+			VersionStatus:     versionStatuses,
 		}
 
-		if err = asset.DownloadConnectorTarballs(connectorPackaging); err != nil {
-			fmt.Println("error downloading connector tarball", err)
-			os.Exit(1)
+		if err = asset.DownloadConnectorTarballs(run, connectorPackaging); err != nil {
+			logger.Error("error downloading connector tarball", "error", err)
+			g.exit()
 		}
 
-		if err = asset.ExtractConnectorTarballs(connectorPackaging); err != nil {
-			fmt.Println("error extracting connector tarballs", err)
-			os.Exit(1)
+		if err = asset.VerifyConnectorTarballSignatures(run, connectorPackaging, sigPolicy); err != nil {
+			logger.Error("error verifying connector tarball signatures", "error", err)
+			g.exit()
 		}
 
-		if err = asset.StoreCLIPluginFiles(connectorPackaging); err != nil {
-			fmt.Println("error downloading the cli plugin files", err)
-			os.Exit(1)
+		if err = asset.ExtractConnectorTarballs(run, connectorPackaging); err != nil {
+			logger.Error("error extracting connector tarballs", "error", err)
+			g.exit()
 		}
 
-		if err = asset.ApplyCLIPluginTransform(dataServerURL, connectorPackaging); err != nil {
-			fmt.Println("error applying cli plugin transforms", err)
-			os.Exit(1)
+		if err = asset.ValidateConnectorDefinitions(run, connectorPackaging); err != nil {
+			logger.Error("invalid connector definitions", "error", err)
+			g.exit()
+		}
+
+		if opts.offline && !opts.keepGoing {
+			artefacts, err := asset.CLIPluginArtefacts(connectorPackaging, opts.extractCLIPlugins)
+			if err != nil {
+				logger.Error("error listing cli plugin files", "error", err)
+				g.exit()
+			}
+			missing := asset.MissingOfflineArtefacts(run, artefacts)
+			if len(missing) > 0 {
+				logger.Error("following cli plugin files are not available offline", "artefacts", asset.FormatArtefacts(missing))
+				g.exit()
+			}
+		}
+
+		if err = asset.StoreCLIPluginFiles(run, connectorPackaging, opts.extractCLIPlugins); err != nil {
+			logger.Error("error downloading the cli plugin files", "error", err)
+			g.exit()
+		}
+
+		if err = asset.WriteSBOMs(run, connectorPackaging, opts.extractCLIPlugins); err != nil {
+			logger.Error("error writing sboms", "error", err)
+			g.exit()
+		}
+
+		if run.Cache != nil {
+			if err = run.Cache.Evict(); err != nil {
+				logger.Error("error trimming the download cache", "error", err)
+				g.exit()
+			}
 		}
 
-		if err = asset.OutputConnectorTarballs(connectorPackaging); err != nil {
-			fmt.Println("error creating connector tarball output", err)
+		if err = asset.ApplyCLIPluginTransform(run, dataServerURL, connectorPackaging, opts.extractCLIPlugins); err != nil {
+			logger.Error("error applying cli plugin transforms", "error", err)
+			g.exit()
+		}
+
+		if err = asset.OutputConnectorTarballs(run, connectorPackaging); err != nil {
+			logger.Error("error creating connector tarball output", "error", err)
+			g.exit()
+		}
+
+		if err = asset.WriteProvenance(run, connectorPackaging, opts.extractCLIPlugins); err != nil {
+			logger.Error("error writing provenance", "error", err)
+			g.exit()
+		}
+
+		if err = run.KeepGoing.RemoveOutputs(); err != nil {
+			logger.Error("error removing the outputs of failed connector versions", "error", err)
+			g.exit()
+		}
+		if err = asset.WriteAggregateSBOM(run, connectorPackaging); err != nil {
+			logger.Error("error writing the aggregate sbom", "error", err)
+			g.exit()
+		}
+		run.KeepGoing.ExcludeFromIndex(index)
+		if err = asset.WriteIndexJSON(index); err != nil {
+			logger.Error("error writing index.json", "error", err)
+			g.exit()
+		}
+
+		if err = asset.WriteOutputManifest(asset.OutputFolderPath, opts.jsonManifest); err != nil {
+			logger.Error("error writing the outputs manifest", "error", err)
+			g.exit()
+		}
+
+		if outputsKey != nil {
+			if err = asset.SignOutputs(outputsKey); err != nil {
+				logger.Error("error signing the outputs", "error", err)
+				g.exit()
+			}
+		} else if err = asset.RemoveOutputSignatures(); err != nil {
+			logger.Error("error removing the signatures of the outputs", "error", err)
+			g.exit()
+		}
+
+		if failures := run.KeepGoing.List(); len(failures) > 0 {
+			logFailureSummary(failures, len(connectorPackaging))
+			g.exit()
+		}
+
+		if err = g.flushMetrics(true); err != nil {
+			logger.Error("error emitting the metrics", "error", err)
+			os.Exit(1)
+		}
+		if err = g.flushTraces(nil); err != nil {
+			logger.Error("error exporting the spans", "error", err)
 			os.Exit(1)
 		}
 	},
 }
 
+// exit exits a failed generate, emitting the metrics and spans of the run
+// first so that the failure can be alerted on and inspected.
+func (g *generation) exit() {
+	if err := g.flushMetrics(false); err != nil {
+		logger.Error("error emitting the metrics", "error", err)
+	}
+	if err := g.flushTraces(errors.New("generate failed")); err != nil {
+		logger.Error("error exporting the spans", "error", err)
+	}
+	os.Exit(1)
+}
+
+// loadSigningKey reads the key the outputs are signed with from the
+// --signing-key file or the DDN_ASSETS_SIGNING_KEY env var. Outputs are not
+// signed when neither is set.
+func loadSigningKey(signingKey string) (crypto.Signer, error) {
+	keyData := []byte(os.Getenv("DDN_ASSETS_SIGNING_KEY"))
+	if signingKey != "" {
+		var err error
+		keyData, err = os.ReadFile(signingKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(keyData) == 0 {
+		return nil, nil
+	}
+	return signature.ParsePrivateKey(keyData)
+}
+
+// loadSignaturePolicy loads the --signature-policy file, the policy is nil
+// when there is none.
+func loadSignaturePolicy(signaturePolicy string) (*signature.Policy, error) {
+	if signaturePolicy == "" {
+		return nil, nil
+	}
+	return signature.LoadPolicy(signaturePolicy)
+}
+
+// newFetchers returns the fetchers of every uri scheme artefacts can be
+// downloaded from, enforcing policy, with the s3 fetcher configured from the
+// environment. file uris read the local disk of the build host, so they are
+// opt-in.
+func newFetchers(allowFileURIs bool, policy *asset.DownloadPolicy) fetch.Fetchers {
+	// a nil policy has to stay a nil interface
+	var p fetch.Policy
+	if policy != nil {
+		p = policy
+	}
+	client := fetch.NewClient(p)
+	fetchers := fetch.NewFetchers(client)
+	fetchers["s3"] = fetch.NewS3FetcherFromEnv(client)
+	if allowFileURIs {
+		fetchers["file"] = fetch.FileFetcher{}
+	}
+	return fetchers
+}
+
+// setupDownloadPolicy loads the --download-policy file, and sets up the
+// fetchers of the run to enforce it.
+func setupDownloadPolicy(run *asset.Run, opts *downloadOptions) error {
+	if opts.downloadPolicy != "" {
+		var err error
+		run.DownloadPolicy, err = asset.LoadDownloadPolicy(opts.downloadPolicy)
+		if err != nil {
+			return err
+		}
+	}
+	run.Fetchers = newFetchers(opts.allowFileURIs, run.DownloadPolicy)
+	return nil
+}
+
+func setupProgress(run *asset.Run, progressMode string) error {
+	mode := progressMode
+	if mode == "auto" {
+		mode = "log"
+		if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("CI") == "" {
+			mode = "tty"
+		}
+	}
+
+	switch mode {
+	case "tty":
+		display := asset.NewProgressDisplay(os.Stderr, logger, true, 200*time.Millisecond)
+		run.Progress = display
+		run.Logger = display.Logger(logger)
+	case "log":
+		run.Progress = asset.NewProgressDisplay(os.Stderr, logger, false, 10*time.Second)
+	case "none":
+	default:
+		return fmt.Errorf("unknown progress display %q, expected auto, tty, log or none", progressMode)
+	}
+	return nil
+}
+
+// logFailureSummary logs the connector versions that failed in keep-going
+// mode, with the stage they failed in and why.
+func logFailureSummary(failures []asset.VersionFailure, total int) {
+	logger.Error(fmt.Sprintf("%d of %d connector versions failed and were left out of the index", len(failures), total))
+	for _, f := range failures {
+		logger.Error("connector version failed", "namespace", f.Namespace, "name", f.Name, "version", f.Version, "stage", f.Stage, "error", f.Err)
+	}
+}
+
+// setupMetrics collects the metrics of the run alongside the progress
+// display when they are to be emitted.
+func (g *generation) setupMetrics() {
+	if g.opts.metricsFile == "" && g.opts.metricsPushURL == "" {
+		return
+	}
+	g.metrics = asset.NewMetrics()
+	if g.run.Progress != nil {
+		g.run.Progress = asset.MultiProgress(g.run.Progress, g.metrics)
+	} else {
+		g.run.Progress = g.metrics
+	}
+}
+
+func (g *generation) flushMetrics(success bool) error {
+	if g.metrics == nil {
+		return nil
+	}
+	if g.opts.metricsFile != "" {
+		if err := g.metrics.WriteTextfile(g.opts.metricsFile, success); err != nil {
+			return err
+		}
+	}
+	if g.opts.metricsPushURL != "" {
+		if err := g.metrics.Push(g.opts.metricsPushURL, success); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupTracing traces the run when an OTLP endpoint or a trace file is set.
+func (g *generation) setupTracing() error {
+	endpoint := g.opts.otlpEndpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+
+	var exporters []tracing.Exporter
+	if endpoint != "" {
+		headers, err := tracing.ParseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+		if err != nil {
+			return fmt.Errorf("error parsing OTEL_EXPORTER_OTLP_HEADERS env var: %w", err)
+		}
+		timeout := tracing.DefaultExportTimeout
+		if ms := os.Getenv("OTEL_EXPORTER_OTLP_TIMEOUT"); ms != "" {
+			n, err := strconv.Atoi(ms)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("OTEL_EXPORTER_OTLP_TIMEOUT env var must be a number of milliseconds, got %q", ms)
+			}
+			timeout = time.Duration(n) * time.Millisecond
+		}
+		exporters = append(exporters, tracing.NewOTLPExporter(endpoint, headers, timeout))
+	}
+	switch g.opts.traceFile {
+	case "":
+	case "-":
+		exporters = append(exporters, &tracing.WriterExporter{W: os.Stdout})
+	default:
+		var err error
+		g.traceFileOut, err = os.Create(g.opts.traceFile)
+		if err != nil {
+			return err
+		}
+		exporters = append(exporters, &tracing.WriterExporter{W: g.traceFileOut})
+	}
+	if len(exporters) == 0 {
+		return nil
+	}
+
+	g.run.Tracer = tracing.NewTracer("generate", []tracing.Attribute{
+		tracing.String("service.name", "ddn-assets"),
+		tracing.String("service.version", version.Get()),
+	}, exporters...)
+	return nil
+}
+
+// flushTraces ends the trace of the run, failed when runErr is set, and
+// exports its spans.
+func (g *generation) flushTraces(runErr error) error {
+	err := g.run.Tracer.Shutdown(runErr)
+	if g.traceFileOut != nil {
+		err = errors.Join(err, g.traceFileOut.Close())
+	}
+	return err
+}
+
+func setupDownloadCache(run *asset.Run, opts *downloadOptions) error {
+	if opts.cacheDir == "" {
+		return nil
+	}
+	var err error
+	run.Cache, err = asset.NewCache(opts.cacheDir, opts.cacheMaxSizeMB*1024*1024)
+	return err
+}
+
+// readRegistry walks the registry folder of an ndc-hub checkout and returns
+// the connectors and the packaging definitions of their versions.
+func readRegistry(ndcHubGitRepoFilePath string) ([]asset.Connector, []ndchub.ConnectorPackaging, error) {
+	registryFolder := filepath.Join(ndcHubGitRepoFilePath, "registry")
+	_, err := os.Stat(registryFolder)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("registry folder does not exist")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while finding the registry folder %w", err)
+	}
+
+	var connectors []asset.Connector
+	var connectorPackaging []ndchub.ConnectorPackaging
+	err = filepath.WalkDir(registryFolder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if filepath.Base(path) == ndchub.MetadataJSON {
+			metadata, err := getConnectorMetadata(path)
+			if err != nil {
+				return err
+			}
+			if metadata != nil {
+				connectors = append(connectors, *metadata)
+			}
+		}
+
+		if filepath.Base(path) == ndchub.ConnectorPackagingJSON {
+			cp, err := ndchub.GetConnectorPackaging(path)
+			if err != nil {
+				return err
+			}
+			if cp != nil {
+				connectorPackaging = append(connectorPackaging, *cp)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while walking the registry folder %w", err)
+	}
+
+	return connectors, connectorPackaging, nil
+}
+
 func getConnectorMetadata(path string) (*asset.Connector, error) {
 	if strings.Contains(path, "aliased_connectors") {
 		// It should be safe to ignore aliased_connectors
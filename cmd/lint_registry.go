@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/spf13/cobra"
+)
+
+var lintFormat string
+
+func init() {
+	lintRegistryCmd.Flags().StringVar(&lintFormat, "format", "text", "diagnostics format: text (file:line: message) or github (workflow annotations)")
+}
+
+var lintRegistryCmd = &cobra.Command{
+	Use:   "lint-registry [ndc-hub folder]",
+	Short: "Check the layout and packaging files of an ndc-hub checkout",
+	Long:  "Check the layout and packaging files of an ndc-hub checkout, defaulting to the NDC_HUB_GIT_REPO_FILE_PATH env var",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ndcHubGitRepoFilePath := os.Getenv("NDC_HUB_GIT_REPO_FILE_PATH")
+		if len(args) > 0 {
+			ndcHubGitRepoFilePath = args[0]
+		}
+		if ndcHubGitRepoFilePath == "" {
+			logger.Error("please pass the ndc-hub folder or set a value for NDC_HUB_GIT_REPO_FILE_PATH env var")
+			os.Exit(1)
+			return
+		}
+		if lintFormat != "text" && lintFormat != "github" {
+			logger.Error("unknown format, expected text or github", "format", lintFormat)
+			os.Exit(1)
+			return
+		}
+
+		diagnostics, err := ndchub.LintRegistry(ndcHubGitRepoFilePath)
+		if err != nil {
+			logger.Error("error linting the registry", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		problems := 0
+		for _, d := range diagnostics {
+			if !d.Warning {
+				problems++
+			}
+			if lintFormat == "github" {
+				level := "error"
+				if d.Warning {
+					level = "warning"
+				}
+				fmt.Printf("::%s file=%s,line=%d::%s\n", level, d.File, max(d.Line, 1), d.Message)
+				continue
+			}
+			fmt.Println(d)
+		}
+		if problems > 0 {
+			logger.Error("found problems in the registry", "problems", problems)
+			os.Exit(1)
+			return
+		}
+		fmt.Println("registry is valid")
+	},
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hasura/ddn-assets/internal/asset"
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/spf13/cobra"
+)
+
+var pruneDryRun bool
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "only list the folders that would be removed")
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove downloaded, extracted and output versions that are no longer in ndc-hub",
+	Run: func(cmd *cobra.Command, args []string) {
+		ndcHubGitRepoFilePath := os.Getenv("NDC_HUB_GIT_REPO_FILE_PATH")
+		if ndcHubGitRepoFilePath == "" {
+			logger.Error("please set a value for NDC_HUB_GIT_REPO_FILE_PATH env var")
+			os.Exit(1)
+			return
+		}
+
+		_, connectorPackaging, err := readRegistry(ndcHubGitRepoFilePath)
+		if err != nil {
+			logger.Error("error reading the registry", "error", err)
+			os.Exit(1)
+			return
+		}
+
+		if err := pruneStaleVersions(connectorPackaging, pruneDryRun); err != nil {
+			logger.Error("error pruning stale versions", "error", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func pruneStaleVersions(connPkgs []ndchub.ConnectorPackaging, dryRun bool) error {
+	stale, err := asset.PruneStaleVersions(connPkgs, dryRun)
+	if err != nil {
+		return err
+	}
+	for _, folder := range stale {
+		if dryRun {
+			fmt.Println("would remove", folder)
+		} else {
+			logger.Info("removed stale folder", "folder", folder)
+		}
+	}
+	return nil
+}
@@ -2,22 +2,52 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/hasura/ddn-assets/internal/asset"
 	"github.com/spf13/cobra"
 )
 
+var (
+	logLevel  string
+	logFormat string
+
+	// logger is set up from the --log-level and --log-format flags before any
+	// command runs.
+	logger = slog.Default()
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "ddn-assets",
 	Short: "Tool for managing Hasura DDN assets",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		logger, err = asset.NewLogger(os.Stderr, logLevel, logFormat)
+		if err != nil {
+			return err
+		}
+		slog.SetDefault(logger)
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		_ = cmd.Help()
 	},
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format: text or json")
+
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(fetchCmd)
+	rootCmd.AddCommand(verifyOutputsCmd)
+	rootCmd.AddCommand(verifySignaturesCmd)
+	rootCmd.AddCommand(lintRegistryCmd)
+	rootCmd.AddCommand(diffRemoteCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(pruneCmd)
 }
 
 func Execute() {
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hasura/ddn-assets/internal/asset"
+	"github.com/spf13/cobra"
+)
+
+var verifyOutputsCmd = &cobra.Command{
+	Use:   "verify-outputs [outputs folder]",
+	Short: "Verify an outputs folder against its SHA256SUMS manifest",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputsFolder := asset.OutputFolderPath
+		if len(args) > 0 {
+			outputsFolder = args[0]
+		}
+
+		report, err := asset.VerifyOutputManifest(outputsFolder)
+		if err != nil {
+			logger.Error("error verifying the outputs manifest", "error", err)
+			os.Exit(1)
+			return
+		}
+		if !report.OK() {
+			fmt.Print(report)
+			logger.Error("outputs do not match the manifest", "folder", outputsFolder)
+			os.Exit(1)
+			return
+		}
+		fmt.Println("outputs match the manifest")
+	},
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/hasura/ddn-assets/internal/asset"
+	"github.com/hasura/ddn-assets/internal/signature"
+	"github.com/spf13/cobra"
+)
+
+var verifyPublicKeys []string
+
+func init() {
+	verifySignaturesCmd.Flags().StringArrayVar(&verifyPublicKeys, "public-key", nil, "PEM public key file the outputs are expected to be signed with (repeatable)")
+	_ = verifySignaturesCmd.MarkFlagRequired("public-key")
+}
+
+var verifySignaturesCmd = &cobra.Command{
+	Use:   "verify-signatures [outputs folder]",
+	Short: "Verify the signatures of a generated outputs folder",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputsFolder := asset.OutputFolderPath
+		if len(args) > 0 {
+			outputsFolder = args[0]
+		}
+
+		var keys []crypto.PublicKey
+		for _, keyFile := range verifyPublicKeys {
+			keyData, err := os.ReadFile(keyFile)
+			if err != nil {
+				logger.Error("error reading public key", "path", keyFile, "error", err)
+				os.Exit(1)
+				return
+			}
+			key, err := signature.ParsePublicKey(keyData)
+			if err != nil {
+				logger.Error("error parsing public key", "path", keyFile, "error", err)
+				os.Exit(1)
+				return
+			}
+			keys = append(keys, key)
+		}
+
+		if err := asset.VerifyOutputs(outputsFolder, keys); err != nil {
+			fmt.Printf("following outputs failed verification:\n%s\n", err)
+			logger.Error("outputs failed verification", "folder", outputsFolder)
+			os.Exit(1)
+			return
+		}
+		fmt.Println("all outputs have valid signatures")
+	},
+}
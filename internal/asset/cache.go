@@ -0,0 +1,193 @@
+package asset
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache stores downloaded artefacts keyed by their SHA-256 checksum, so that
+// identical artefacts are only fetched once across versions, runs and
+// checkouts.
+type Cache struct {
+	Dir string
+	// MaxSize is the total size in bytes the cache is trimmed down to by
+	// Evict, dropping the least recently used artefacts first. Zero means
+	// unlimited.
+	MaxSize int64
+
+	mu sync.Mutex
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/ddn-assets, falling back to the
+// user cache directory of the platform.
+func DefaultCacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "ddn-assets")
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "ddn-assets")
+	}
+	return filepath.Join(dir, "ddn-assets")
+}
+
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	err := os.MkdirAll(filepath.Join(dir, "sha256"), 0777)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cache folder: %s %w", dir, err)
+	}
+	return &Cache{Dir: dir, MaxSize: maxSize}, nil
+}
+
+func (c *Cache) blobPath(sha256checksum string) string {
+	prefix := sha256checksum
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(c.Dir, "sha256", prefix, sha256checksum)
+}
+
+// Has reports whether an artefact with the checksum is in the cache.
+func (c *Cache) Has(sha256checksum string) bool {
+	if sha256checksum == "" {
+		return false
+	}
+	_, err := os.Stat(c.blobPath(sha256checksum))
+	return err == nil
+}
+
+// Get copies the artefact with the checksum to destPath. It returns false
+// when the cache does not hold a valid copy of the artefact.
+func (c *Cache) Get(sha256checksum, destPath string) (bool, error) {
+	if sha256checksum == "" {
+		return false, nil
+	}
+
+	blob := c.blobPath(sha256checksum)
+	sha, err := getSHAIfFileExists(blob)
+	if err != nil {
+		return false, nil
+	}
+	if sha != sha256checksum {
+		// a corrupted entry is dropped and fetched again
+		_ = os.Remove(blob)
+		return false, nil
+	}
+
+	if err := copyFile(blob, destPath); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(blob, now, now)
+	return true, nil
+}
+
+// Put adds the file at srcPath to the cache and returns its checksum.
+func (c *Cache) Put(srcPath string) (string, error) {
+	sha, err := getSHAIfFileExists(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	blob := c.blobPath(sha)
+	if _, err := os.Stat(blob); err == nil {
+		now := time.Now()
+		return sha, os.Chtimes(blob, now, now)
+	}
+
+	err = os.MkdirAll(filepath.Dir(blob), 0777)
+	if err != nil {
+		return "", fmt.Errorf("error creating folder: %s %w", filepath.Dir(blob), err)
+	}
+
+	// copy next to the final location first, so that concurrent readers
+	// never observe a partially written artefact
+	tmp, err := os.CreateTemp(filepath.Dir(blob), ".tmp-"+sha)
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := copyFile(srcPath, tmp.Name()); err != nil {
+		return "", err
+	}
+	return sha, os.Rename(tmp.Name(), blob)
+}
+
+// Evict removes the least recently used artefacts until the cache fits in
+// MaxSize.
+func (c *Cache) Evict() error {
+	if c.MaxSize <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	var total int64
+	err := filepath.WalkDir(filepath.Join(c.Dir, "sha256"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking the cache folder: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	for _, e := range entries {
+		if total <= c.MaxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil {
+			return err
+		}
+		total -= e.size
+	}
+	return nil
+}
+
+func copyFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	if _, err := io.Copy(dest, src); err != nil {
+		return err
+	}
+	return dest.Close()
+}
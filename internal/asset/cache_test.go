@@ -0,0 +1,66 @@
+package asset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcDir := t.TempDir()
+	writeFile := func(name, content string) string {
+		p := filepath.Join(srcDir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	first, err := cache.Put(writeFile("first", "first artefact"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same content referenced by another version is stored once
+	if sha, err := cache.Put(writeFile("copy", "first artefact")); err != nil || sha != first {
+		t.Fatalf("expected identical artefacts to share a checksum, got %s, %v", sha, err)
+	}
+
+	dest := filepath.Join(srcDir, "restored")
+	hit, err := cache.Get(first, dest)
+	if err != nil || !hit {
+		t.Fatalf("expected a cache hit, got %v, %v", hit, err)
+	}
+	if content, _ := os.ReadFile(dest); string(content) != "first artefact" {
+		t.Errorf("unexpected restored content %q", content)
+	}
+
+	if hit, _ := cache.Get("0000", dest); hit {
+		t.Error("expected a cache miss for an unknown checksum")
+	}
+
+	second, err := cache.Put(writeFile("second", "second artefact"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(cache.blobPath(first), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.MaxSize = int64(len("second artefact"))
+	if err := cache.Evict(); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Has(first) {
+		t.Error("expected the least recently used artefact to be evicted")
+	}
+	if !cache.Has(second) {
+		t.Error("expected the most recently used artefact to be kept")
+	}
+}
@@ -0,0 +1,177 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReadIndexJSON reads an index.json file, or the index.json of an outputs
+// folder.
+func ReadIndexJSON(path string) (*Index, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, "index.json")
+	}
+
+	indexJson, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(indexJson, &index); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return &index, nil
+}
+
+// IndexDiff lists what changed between two index.json files. Connectors are
+// given as namespace/name and versions as namespace/name/version.
+type IndexDiff struct {
+	AddedConnectors    []string            `json:"added_connectors"`
+	RemovedConnectors  []string            `json:"removed_connectors"`
+	AddedVersions      []string            `json:"added_versions"`
+	RemovedVersions    []string            `json:"removed_versions"`
+	LatestVersionBumps []LatestVersionBump `json:"latest_version_bumps"`
+}
+
+type LatestVersionBump struct {
+	Connector string `json:"connector"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+func (d *IndexDiff) Empty() bool {
+	return len(d.AddedConnectors) == 0 && len(d.RemovedConnectors) == 0 &&
+		len(d.AddedVersions) == 0 && len(d.RemovedVersions) == 0 &&
+		len(d.LatestVersionBumps) == 0
+}
+
+// latestVersions maps the connectors of an index, including the ones that
+// only have versions, to their latest version.
+func latestVersions(index *Index) map[string]string {
+	latest := make(map[string]string)
+	for slug := range index.ConnectorVersions {
+		latest[slug] = ""
+	}
+	for _, c := range index.Connectors {
+		latest[fmt.Sprintf("%s/%s", c.Namespace, c.Name)] = c.LatestVersion
+	}
+	return latest
+}
+
+func indexVersions(index *Index) map[string]bool {
+	versions := make(map[string]bool)
+	for slug, vs := range index.ConnectorVersions {
+		for _, v := range vs {
+			versions[slug+"/"+v] = true
+		}
+	}
+	return versions
+}
+
+// missingKeys returns the sorted keys of a that are not in b.
+func missingKeys[V any](a, b map[string]V) []string {
+	keys := []string{}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DiffIndexes compares the index.json of a regeneration with the previous
+// one.
+func DiffIndexes(oldIndex, newIndex *Index) *IndexDiff {
+	oldLatest, newLatest := latestVersions(oldIndex), latestVersions(newIndex)
+	oldVersions, newVersions := indexVersions(oldIndex), indexVersions(newIndex)
+
+	diff := &IndexDiff{
+		AddedConnectors:    missingKeys(newLatest, oldLatest),
+		RemovedConnectors:  missingKeys(oldLatest, newLatest),
+		AddedVersions:      missingKeys(newVersions, oldVersions),
+		RemovedVersions:    missingKeys(oldVersions, newVersions),
+		LatestVersionBumps: []LatestVersionBump{},
+	}
+	for slug, to := range newLatest {
+		if from, ok := oldLatest[slug]; ok && from != to {
+			diff.LatestVersionBumps = append(diff.LatestVersionBumps, LatestVersionBump{Connector: slug, From: from, To: to})
+		}
+	}
+	sort.Slice(diff.LatestVersionBumps, func(i, j int) bool {
+		return diff.LatestVersionBumps[i].Connector < diff.LatestVersionBumps[j].Connector
+	})
+	return diff
+}
+
+func (d *IndexDiff) String() string {
+	var sb strings.Builder
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"new connector", d.AddedConnectors},
+		{"removed connector", d.RemovedConnectors},
+		{"new version", d.AddedVersions},
+		{"removed version", d.RemovedVersions},
+	} {
+		for _, item := range section.items {
+			fmt.Fprintf(&sb, "%s: %s\n", section.title, item)
+		}
+	}
+	for _, bump := range d.LatestVersionBumps {
+		fmt.Fprintf(&sb, "latest version: %s %s -> %s\n", bump.Connector, orNone(bump.From), orNone(bump.To))
+	}
+	return sb.String()
+}
+
+// Markdown renders the diff as a changelog for pull request comments and
+// release notes.
+func (d *IndexDiff) Markdown() string {
+	if d.Empty() {
+		return "No connector changes.\n"
+	}
+
+	var sb strings.Builder
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"New connectors", d.AddedConnectors},
+		{"Removed connectors", d.RemovedConnectors},
+		{"New versions", d.AddedVersions},
+		{"Removed versions", d.RemovedVersions},
+	} {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "### %s\n\n", section.title)
+		for _, item := range section.items {
+			fmt.Fprintf(&sb, "- `%s`\n", item)
+		}
+		sb.WriteString("\n")
+	}
+	if len(d.LatestVersionBumps) > 0 {
+		sb.WriteString("### Latest versions\n\n| Connector | From | To |\n| --- | --- | --- |\n")
+		for _, bump := range d.LatestVersionBumps {
+			fmt.Fprintf(&sb, "| `%s` | %s | %s |\n", bump.Connector, orNone(bump.From), orNone(bump.To))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func orNone(version string) string {
+	if version == "" {
+		return "(none)"
+	}
+	return version
+}
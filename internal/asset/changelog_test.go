@@ -0,0 +1,54 @@
+package asset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffIndexes(t *testing.T) {
+	oldIndex := &Index{
+		Connectors: []Connector{
+			{Namespace: "hasura", Name: "foo", LatestVersion: "v1"},
+			{Namespace: "hasura", Name: "bar", LatestVersion: "v1"},
+		},
+		ConnectorVersions: map[string][]string{
+			"hasura/foo": {"v1"},
+			"hasura/bar": {"v1"},
+		},
+	}
+	newIndex := &Index{
+		Connectors: []Connector{
+			{Namespace: "hasura", Name: "foo", LatestVersion: "v2"},
+			{Namespace: "acme", Name: "baz", LatestVersion: "v0.1.0"},
+		},
+		ConnectorVersions: map[string][]string{
+			"hasura/foo": {"v1", "v2"},
+			"acme/baz":   {"v0.1.0"},
+		},
+	}
+
+	diff := DiffIndexes(oldIndex, newIndex)
+	expected := &IndexDiff{
+		AddedConnectors:    []string{"acme/baz"},
+		RemovedConnectors:  []string{"hasura/bar"},
+		AddedVersions:      []string{"acme/baz/v0.1.0", "hasura/foo/v2"},
+		RemovedVersions:    []string{"hasura/bar/v1"},
+		LatestVersionBumps: []LatestVersionBump{{Connector: "hasura/foo", From: "v1", To: "v2"}},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("unexpected diff\n got: %+v\nwant: %+v", diff, expected)
+	}
+
+	expectedMarkdown := "### New connectors\n\n- `acme/baz`\n\n" +
+		"### Removed connectors\n\n- `hasura/bar`\n\n" +
+		"### New versions\n\n- `acme/baz/v0.1.0`\n- `hasura/foo/v2`\n\n" +
+		"### Removed versions\n\n- `hasura/bar/v1`\n\n" +
+		"### Latest versions\n\n| Connector | From | To |\n| --- | --- | --- |\n| `hasura/foo` | v1 | v2 |\n"
+	if got := diff.Markdown(); got != expectedMarkdown {
+		t.Errorf("unexpected markdown\n got: %q\nwant: %q", got, expectedMarkdown)
+	}
+
+	if diff := DiffIndexes(newIndex, newIndex); !diff.Empty() {
+		t.Errorf("expected no changes against itself, got:\n%s", diff)
+	}
+}
@@ -1,12 +1,14 @@
 package asset
 
 import (
+	"fmt"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 
 	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 )
@@ -82,122 +84,192 @@ func (*DockerCLIPluginDefinition) GetType() CLIPluginType {
 }
 
 type BinaryCLIPluginPlatform struct {
-	Selector string
-	URI      string
-	SHA256   string
-	Bin      string
+	Selector string `yaml:"selector"`
+	URI      string `yaml:"uri"`
+	SHA256   string `yaml:"sha256"`
+	Bin      string `yaml:"bin"`
 }
 
-func ApplyCLIPluginTransform(dataServerBaseURL *url.URL, connPkgs []ndchub.ConnectorPackaging) error {
-	var transform errgroup.Group
-	for _, cp := range connPkgs {
-		transform.Go(func() error {
-			connMetadataFilePath := filepath.Join(
-				extractedConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version),
-				".hasura-connector", "connector-metadata.yaml",
-			)
-
-			data, err := os.ReadFile(connMetadataFilePath)
+// ApplyCLIPluginTransform points the BinaryInline cli plugin platforms of every
+// connector version at the data server. With extractBinaries, the platforms
+// point at the standalone binaries stored by StoreCLIPluginFiles and carry
+// their checksums.
+func ApplyCLIPluginTransform(run *Run, dataServerBaseURL *url.URL, connPkgs []ndchub.ConnectorPackaging, extractBinaries bool) error {
+	return forEachVersion(run, StageCLIPluginTransform, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		connMetadataFilePath := connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version)
+		cliPlugin, data, err := readBinaryInlineCLIPlugin(connMetadataFilePath)
+		if err != nil {
+			return err
+		}
+		if cliPlugin == nil {
+			return nil
+		}
+
+		for idx := 0; idx < len(cliPlugin.Platforms); idx++ {
+			p := cliPlugin.Platforms[idx]
+
+			downloadUrl, err := url.Parse(p.URI)
 			if err != nil {
 				return err
 			}
+			fileName := path.Base(downloadUrl.Path)
 
-			var connMetadata ConnectorMetadataYAML
-			err = yaml.Unmarshal(data, &connMetadata)
-			if err != nil {
-				return err
+			if extractBinaries {
+				binPath := cliPluginBinaryPath(cp.Namespace, cp.Name, cp.Version, p.Selector, p.Bin)
+				sha, err := getSHAIfFileExists(binPath)
+				if err != nil {
+					return fmt.Errorf("error reading cli plugin binary for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+				}
+				fileName = filepath.Base(binPath)
+				cliPlugin.Platforms[idx].SHA256 = sha
 			}
 
-			cliPlugin, ok := connMetadata.CLIPlugin.(*BinaryInlineCLIPluginDefinition)
+			cliPlugin.Platforms[idx].URI = dataServerBaseURL.ResolveReference(&url.URL{Path: path.Join(
+				cp.Namespace,
+				cp.Name,
+				cp.Version,
+				p.Selector,
+				fileName,
+			)}).String()
+		}
+
+		var connMetadataMap map[string]any
+		err = yaml.Unmarshal(data, &connMetadataMap)
+		if err != nil {
+			return err
+		}
+		// only the uri and sha256 of each platform are updated in place, so that
+		// the plugin type and any other field survive the rewrite
+		cliPluginMap, _ := connMetadataMap["cliPlugin"].(map[string]any)
+		platforms, _ := cliPluginMap["platforms"].([]any)
+		if len(platforms) != len(cliPlugin.Platforms) {
+			return fmt.Errorf("unexpected cli plugin platforms in %s", connMetadataFilePath)
+		}
+		for idx, p := range cliPlugin.Platforms {
+			platform, ok := platforms[idx].(map[string]any)
 			if !ok {
-				return nil
+				return fmt.Errorf("unexpected cli plugin platform in %s", connMetadataFilePath)
 			}
+			platform["uri"] = p.URI
+			platform["sha256"] = p.SHA256
+		}
+
+		newConnMetadata, err := yaml.Marshal(connMetadataMap)
+		if err != nil {
+			return err
+		}
+
+		stat, err := os.Stat(connMetadataFilePath)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(connMetadataFilePath, newConnMetadata, stat.Mode())
+	})
+}
 
-			for idx := 0; idx < len(cliPlugin.Platforms); idx++ {
-				p := cliPlugin.Platforms[idx]
+// StoreCLIPluginFiles downloads the platform files of every BinaryInline cli
+// plugin. When extractBinaries is set, the declared bin is unpacked from each
+// platform archive and stored as a standalone binary, next to a .sha256 file,
+// instead of storing the archive itself.
+func StoreCLIPluginFiles(run *Run, connPkgs []ndchub.ConnectorPackaging, extractBinaries bool) error {
+	return forEachVersion(run, StageCLIPlugins, connPkgs, func(span *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		cliPlugin, _, err := readBinaryInlineCLIPlugin(connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version))
+		if err != nil {
+			return err
+		}
+		if cliPlugin == nil {
+			return nil
+		}
+
+		if err := cliPlugin.Validate(); err != nil {
+			return fmt.Errorf("invalid cli plugin definition for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+		}
+
+		for idx, p := range cliPlugin.Platforms {
+			if err := checkURI(run, uriSource(cp, fmt.Sprintf("connector-metadata.yaml cliPlugin.platforms[%d].uri", idx)), p.URI); err != nil {
+				return err
+			}
+		}
 
-				downloadUrl, err := url.Parse(p.URI)
+		var cliPluginDownload errgroup.Group
+		for _, p := range cliPlugin.Platforms {
+			cliPluginDownload.Go(func() error {
+				pluginPath, err := cliPluginDownloadPath(cp, p, extractBinaries)
 				if err != nil {
 					return err
 				}
+				err = os.MkdirAll(filepath.Dir(pluginPath), 0777)
+				if err != nil {
+					return fmt.Errorf("error creating folder: %s %w", filepath.Dir(pluginPath), err)
+				}
+				if !extractBinaries {
+					if err := run.KeepGoing.Wrote(cp, pluginPath); err != nil {
+						return err
+					}
+				}
 
-				cliPlugin.Platforms[idx].URI = dataServerBaseURL.ResolveReference(&url.URL{Path: path.Join(
-					cp.Namespace,
-					cp.Name,
-					cp.Version,
-					p.Selector,
-					path.Base(downloadUrl.Path),
-				)}).String()
-			}
-
-			var connMetadataMap map[string]any
-			err = yaml.Unmarshal(data, &connMetadataMap)
-			if err != nil {
-				return err
-			}
-			connMetadataMap["cliPlugin"] = cliPlugin
-
-			newConnMetadata, err := yaml.Marshal(connMetadataMap)
-			if err != nil {
-				return err
-			}
+				err = downloadFile(run, span, connectorVersionLogger(run, cp).With("selector", p.Selector), p.URI, pluginPath, p.SHA256)
+				if err != nil {
+					return err
+				}
 
-			stat, err := os.Stat(connMetadataFilePath)
-			if err != nil {
-				return err
-			}
+				if err := VerifyCLIPluginBinary(pluginPath, p); err != nil {
+					return fmt.Errorf("invalid cli plugin binary for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+				}
 
-			return os.WriteFile(connMetadataFilePath, newConnMetadata, stat.Mode())
-		})
-	}
-	return transform.Wait()
+				if extractBinaries {
+					binPath := cliPluginBinaryPath(cp.Namespace, cp.Name, cp.Version, p.Selector, p.Bin)
+					for _, path := range []string{binPath, binPath + ".sha256"} {
+						if err := run.KeepGoing.Wrote(cp, path); err != nil {
+							return err
+						}
+					}
+					if err := extractCLIPluginBinary(pluginPath, binPath, p.Selector, p.Bin); err != nil {
+						return fmt.Errorf("error extracting cli plugin binary for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+					}
+				}
+				return nil
+			})
+		}
+		return cliPluginDownload.Wait()
+	})
 }
 
-func StoreCLIPluginFiles(connPkgs []ndchub.ConnectorPackaging) error {
-	var download errgroup.Group
-	for _, cp := range connPkgs {
-		download.Go(func() error {
-			connMetadataFilePath := filepath.Join(
-				extractedConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version),
-				".hasura-connector", "connector-metadata.yaml",
-			)
-
-			data, err := os.ReadFile(connMetadataFilePath)
-			if err != nil {
-				return err
-			}
+// readBinaryInlineCLIPlugin reads a connector-metadata.yaml file and returns
+// its raw content and the cli plugin definition, which is nil when the cli
+// plugin is not a BinaryInline one.
+func readBinaryInlineCLIPlugin(connMetadataFilePath string) (*BinaryInlineCLIPluginDefinition, []byte, error) {
+	data, err := os.ReadFile(connMetadataFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			var connMetadata ConnectorMetadataYAML
-			err = yaml.Unmarshal(data, &connMetadata)
-			if err != nil {
-				return err
-			}
+	var connMetadata ConnectorMetadataYAML
+	err = yaml.Unmarshal(data, &connMetadata)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			cliPlugin, ok := connMetadata.CLIPlugin.(*BinaryInlineCLIPluginDefinition)
-			if !ok {
-				return nil
-			}
+	cliPlugin, ok := connMetadata.CLIPlugin.(*BinaryInlineCLIPluginDefinition)
+	if !ok {
+		return nil, data, nil
+	}
+	return cliPlugin, data, nil
+}
 
-			var cliPluginDownload errgroup.Group
-			for _, p := range cliPlugin.Platforms {
-				cliPluginDownload.Go(func() error {
-					downloadUrl, err := url.Parse(p.URI)
-					if err != nil {
-						return err
-					}
+// cliPluginDownloadPath returns where the file of a cli plugin platform is
+// downloaded to. Archives that are only unpacked are kept with the downloads
+// rather than the outputs.
+func cliPluginDownloadPath(cp ndchub.ConnectorPackaging, p BinaryCLIPluginPlatform, extractBinaries bool) (string, error) {
+	downloadUrl, err := url.Parse(p.URI)
+	if err != nil {
+		return "", err
+	}
 
-					return downloadFile(
-						p.URI,
-						filepath.Join(
-							cliPluginFolder(cp.Namespace, cp.Name, cp.Version),
-							p.Selector, path.Base(downloadUrl.Path),
-						),
-						p.SHA256,
-					)
-				})
-			}
-			return cliPluginDownload.Wait()
-		})
+	folder := cliPluginFolder(cp.Namespace, cp.Name, cp.Version)
+	if extractBinaries {
+		folder = cliPluginDownloadFolder(cp.Namespace, cp.Name, cp.Version)
 	}
-	return download.Wait()
+	return filepath.Join(folder, p.Selector, path.Base(downloadUrl.Path)), nil
 }
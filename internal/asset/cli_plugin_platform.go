@@ -0,0 +1,326 @@
+package asset
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// KnownPlatformSelectors is the set of selectors the DDN CLI resolves a
+// BinaryInline cli plugin platform against.
+var KnownPlatformSelectors = []string{
+	"linux-amd64",
+	"linux-arm64",
+	"darwin-amd64",
+	"darwin-arm64",
+	"windows-amd64",
+	"windows-arm64",
+}
+
+func isKnownPlatformSelector(selector string) bool {
+	return containsString(KnownPlatformSelectors, selector)
+}
+
+// Validate checks the structure of an inline cli plugin definition and
+// reports every problem found instead of stopping at the first one.
+func (d *BinaryInlineCLIPluginDefinition) Validate() error {
+	if len(d.Platforms) == 0 {
+		return errors.New("no platforms defined")
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for idx, p := range d.Platforms {
+		if p.Selector == "" {
+			errs = append(errs, fmt.Errorf("platforms[%d]: missing selector", idx))
+		} else if !isKnownPlatformSelector(p.Selector) {
+			errs = append(errs, fmt.Errorf("platforms[%d]: unknown selector %q", idx, p.Selector))
+		} else if seen[p.Selector] {
+			errs = append(errs, fmt.Errorf("platforms[%d]: duplicate selector %q", idx, p.Selector))
+		}
+		seen[p.Selector] = true
+
+		if p.URI == "" {
+			errs = append(errs, fmt.Errorf("platforms[%d]: missing uri", idx))
+		}
+		if p.Bin == "" {
+			errs = append(errs, fmt.Errorf("platforms[%d]: missing bin", idx))
+		}
+		if p.SHA256 == "" {
+			errs = append(errs, fmt.Errorf("platforms[%d]: missing sha256", idx))
+		} else if b, err := hex.DecodeString(p.SHA256); err != nil || len(b) != 32 {
+			errs = append(errs, fmt.Errorf("platforms[%d]: invalid sha256 %q", idx, p.SHA256))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// VerifyCLIPluginBinary inspects a downloaded cli plugin file and checks that
+// the declared bin exists in it and is an executable built for the platform
+// selector. The file may be a tar.gz or zip archive or the binary itself.
+func VerifyCLIPluginBinary(filePath string, p BinaryCLIPluginPlatform) error {
+	plugin, err := openCLIPluginBinary(filePath, p.Selector, p.Bin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p.Selector, err)
+	}
+	defer plugin.Close()
+
+	// only the executable headers are read, not the whole binary
+	header := make([]byte, binaryHeaderSize)
+	n, err := io.ReadFull(plugin, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("%s: error reading %s: %w", p.Selector, p.Bin, err)
+	}
+
+	platforms, err := detectBinaryPlatforms(header[:n])
+	if err != nil {
+		return fmt.Errorf("%s: %s is not an executable: %w", p.Selector, p.Bin, err)
+	}
+	for _, platform := range platforms {
+		if platform == p.Selector {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %s is built for %s", p.Selector, p.Bin, strings.Join(platforms, ", "))
+}
+
+// binaryReader streams a cli plugin binary, closing the archive it is read
+// from along with it.
+type binaryReader struct {
+	io.Reader
+	close func() error
+}
+
+func (r binaryReader) Close() error {
+	return r.close()
+}
+
+// openCLIPluginBinary opens bin in the cli plugin file at filePath for the
+// platform selector. Archives are searched for an entry whose base name
+// matches bin, anything else is treated as the binary itself.
+func openCLIPluginBinary(filePath, selector, bin string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(file, magic)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	magic = magic[:n]
+
+	var entry io.ReadCloser
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		entry, err = openBinFromTarGz(file, selector, bin)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		var stat os.FileInfo
+		stat, err = file.Stat()
+		if err == nil {
+			entry, err = openBinFromZip(file, stat.Size(), selector, bin)
+		}
+	default:
+		return file, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return binaryReader{entry, func() error {
+		return errors.Join(entry.Close(), file.Close())
+	}}, nil
+}
+
+func isPluginBin(entryName, bin string) bool {
+	base := path.Base(strings.ReplaceAll(entryName, "\\", "/"))
+	return base == bin || base == bin+".exe"
+}
+
+// mustBeExecutable reports whether an archive entry for the platform selector
+// needs its executable bit set, which windows binaries do not have.
+func mustBeExecutable(selector, entryName string) bool {
+	return !strings.HasPrefix(selector, "windows-") && !strings.HasSuffix(entryName, ".exe")
+}
+
+func openBinFromTarGz(r io.Reader, selector, bin string) (io.ReadCloser, error) {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not create gzip reader: %v", err)
+	}
+	tarReader := tar.NewReader(gzReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			gzReader.Close()
+			return nil, fmt.Errorf("bin %s not found in archive", bin)
+		}
+		if err != nil {
+			gzReader.Close()
+			return nil, fmt.Errorf("could not read tar header: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg || !isPluginBin(header.Name, bin) {
+			continue
+		}
+		if mustBeExecutable(selector, header.Name) && header.Mode&0111 == 0 {
+			gzReader.Close()
+			return nil, fmt.Errorf("bin %s is not executable (mode %o)", header.Name, header.Mode)
+		}
+		return binaryReader{tarReader, gzReader.Close}, nil
+	}
+}
+
+func openBinFromZip(r io.ReaderAt, size int64, selector, bin string) (io.ReadCloser, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("could not create zip reader: %v", err)
+	}
+
+	for _, f := range zipReader.File {
+		if f.FileInfo().IsDir() || !isPluginBin(f.Name, bin) {
+			continue
+		}
+		if mustBeExecutable(selector, f.Name) && f.Mode()&0111 == 0 {
+			return nil, fmt.Errorf("bin %s is not executable (mode %o)", f.Name, f.Mode().Perm())
+		}
+		return f.Open()
+	}
+	return nil, fmt.Errorf("bin %s not found in archive", bin)
+}
+
+var (
+	elfArches = map[elf.Machine]string{
+		elf.EM_X86_64:  "amd64",
+		elf.EM_AARCH64: "arm64",
+	}
+	machoArches = map[macho.Cpu]string{
+		macho.CpuAmd64: "amd64",
+		macho.CpuArm64: "arm64",
+	}
+	peArches = map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+		pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	}
+)
+
+// binaryHeaderSize is how much of a binary detectBinaryPlatforms is given,
+// enough for the headers of every format it reads.
+const binaryHeaderSize = 4096
+
+// detectBinaryPlatforms reads the executable headers at the start of a binary
+// and returns the platforms the binary runs on, named like the platform
+// selectors. Only a universal Mach-O binary returns more than one.
+func detectBinaryPlatforms(header []byte) ([]string, error) {
+	le, be := binary.LittleEndian, binary.BigEndian
+	switch {
+	case bytes.HasPrefix(header, []byte(elf.ELFMAG)):
+		if len(header) < 20 {
+			return nil, errors.New("truncated elf header")
+		}
+		var order binary.ByteOrder = le
+		if elf.Data(header[elf.EI_DATA]) == elf.ELFDATA2MSB {
+			order = be
+		}
+		machine := elf.Machine(order.Uint16(header[18:20]))
+		arch, ok := elfArches[machine]
+		if !ok {
+			return nil, fmt.Errorf("unsupported architecture %s", machine)
+		}
+		return []string{"linux-" + arch}, nil
+
+	case len(header) >= 8 && (le.Uint32(header) == macho.Magic32 || le.Uint32(header) == macho.Magic64):
+		return machoPlatforms(macho.Cpu(le.Uint32(header[4:8])))
+	case len(header) >= 8 && (be.Uint32(header) == macho.Magic32 || be.Uint32(header) == macho.Magic64):
+		return machoPlatforms(macho.Cpu(be.Uint32(header[4:8])))
+
+	case len(header) >= 8 && be.Uint32(header) == macho.MagicFat:
+		// the fat header is followed by 20 bytes per architecture, the cpu
+		// type first
+		var platforms []string
+		for i := 0; i < int(be.Uint32(header[4:8])); i++ {
+			offset := 8 + i*20
+			if offset+4 > len(header) {
+				return nil, errors.New("truncated universal binary header")
+			}
+			if arch, ok := machoArches[macho.Cpu(be.Uint32(header[offset:offset+4]))]; ok {
+				platforms = append(platforms, "darwin-"+arch)
+			}
+		}
+		if len(platforms) == 0 {
+			return nil, errors.New("universal binary has no supported architecture")
+		}
+		return platforms, nil
+
+	case bytes.HasPrefix(header, []byte("MZ")):
+		if len(header) < 0x40 {
+			return nil, errors.New("truncated dos header")
+		}
+		// the dos header points to the pe signature, followed by the machine
+		offset := int(le.Uint32(header[0x3c:0x40]))
+		if offset < 0 || offset+6 > len(header) || !bytes.Equal(header[offset:offset+4], []byte("PE\x00\x00")) {
+			return nil, errors.New("pe header not found")
+		}
+		machine := le.Uint16(header[offset+4 : offset+6])
+		arch, ok := peArches[machine]
+		if !ok {
+			return nil, fmt.Errorf("unsupported architecture 0x%x", machine)
+		}
+		return []string{"windows-" + arch}, nil
+	}
+
+	return nil, errors.New("unrecognised executable format")
+}
+
+func machoPlatforms(cpu macho.Cpu) ([]string, error) {
+	arch, ok := machoArches[cpu]
+	if !ok {
+		return nil, fmt.Errorf("unsupported architecture %s", cpu)
+	}
+	return []string{"darwin-" + arch}, nil
+}
+
+// extractCLIPluginBinary unpacks bin from the cli plugin file at archivePath
+// for the platform selector into destPath and writes its checksum to
+// destPath + ".sha256".
+func extractCLIPluginBinary(archivePath, destPath, selector, bin string) error {
+	plugin, err := openCLIPluginBinary(archivePath, selector, bin)
+	if err != nil {
+		return err
+	}
+	defer plugin.Close()
+
+	err = os.MkdirAll(filepath.Dir(destPath), 0777)
+	if err != nil {
+		return fmt.Errorf("error creating folder: %s %w", filepath.Dir(destPath), err)
+	}
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", destPath, err)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(dest, hash), plugin)
+	if err = errors.Join(err, dest.Close()); err != nil {
+		return fmt.Errorf("error writing %s: %w", destPath, err)
+	}
+
+	checksum := fmt.Sprintf("%x  %s\n", hash.Sum(nil), filepath.Base(destPath))
+	return os.WriteFile(destPath+".sha256", []byte(checksum), 0644)
+}
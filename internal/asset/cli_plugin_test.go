@@ -1,8 +1,20 @@
 package asset
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/hasura/ddn-assets/internal/ndchub"
 	"gopkg.in/yaml.v3"
 )
 
@@ -70,3 +82,325 @@ cliPlugin:
 		})
 	}
 }
+
+func TestValidateBinaryInlineCLIPlugin(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	tt := []struct {
+		Name       string
+		Platforms  []BinaryCLIPluginPlatform
+		ErrorCount int
+	}{
+		{
+			Name: "Valid platforms",
+			Platforms: []BinaryCLIPluginPlatform{
+				{Selector: "linux-amd64", URI: "https://example.com/a", SHA256: sha, Bin: "plugin"},
+				{Selector: "darwin-arm64", URI: "https://example.com/b", SHA256: sha, Bin: "plugin"},
+			},
+		},
+		{
+			Name:       "No platforms",
+			ErrorCount: 1,
+		},
+		{
+			Name: "Unknown and duplicate selectors",
+			Platforms: []BinaryCLIPluginPlatform{
+				{Selector: "linux-x86_64", URI: "https://example.com/a", SHA256: sha, Bin: "plugin"},
+				{Selector: "linux-amd64", URI: "https://example.com/b", SHA256: sha, Bin: "plugin"},
+				{Selector: "linux-amd64", URI: "https://example.com/c", SHA256: sha, Bin: "plugin"},
+			},
+			ErrorCount: 2,
+		},
+		{
+			Name: "Missing bin and checksum",
+			Platforms: []BinaryCLIPluginPlatform{
+				{Selector: "windows-amd64", URI: "https://example.com/a"},
+				{Selector: "linux-arm64", URI: "https://example.com/b", SHA256: "not-a-sha", Bin: "plugin"},
+			},
+			ErrorCount: 3,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := (&BinaryInlineCLIPluginDefinition{Platforms: tc.Platforms}).Validate()
+			if tc.ErrorCount == 0 {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected %d errors, got none", tc.ErrorCount)
+			}
+			if got := len(strings.Split(err.Error(), "\n")); got != tc.ErrorCount {
+				t.Errorf("expected %d errors, got %d: %v", tc.ErrorCount, got, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCLIPluginBinary(t *testing.T) {
+	selector := runtime.GOOS + "-" + runtime.GOARCH
+	if !isKnownPlatformSelector(selector) {
+		t.Skipf("test binary platform %s is not a known selector", selector)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exeContent, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "plugin.tar.gz")
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzWriter := gzip.NewWriter(archive)
+	tarWriter := tar.NewWriter(gzWriter)
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:     "bin/hasura-plugin",
+		Mode:     0755,
+		Size:     int64(len(exeContent)),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tarWriter.Write(exeContent); err != nil {
+		t.Fatal(err)
+	}
+	tarWriter.Close()
+	gzWriter.Close()
+	archive.Close()
+
+	writeZip := func(name string, mode os.FileMode) string {
+		zipPath := filepath.Join(t.TempDir(), name)
+		archive, err := os.Create(zipPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zipWriter := zip.NewWriter(archive)
+		header := &zip.FileHeader{Name: "bin/hasura-plugin", Method: zip.Store}
+		header.SetMode(mode)
+		w, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(exeContent); err != nil {
+			t.Fatal(err)
+		}
+		zipWriter.Close()
+		archive.Close()
+		return zipPath
+	}
+	zipPath := writeZip("plugin.zip", 0755)
+	notExecutableZipPath := writeZip("not-executable.zip", 0644)
+
+	tt := []struct {
+		Name        string
+		Platform    BinaryCLIPluginPlatform
+		FilePath    string
+		ExpectError bool
+	}{
+		{
+			Name:     "Binary inside archive",
+			Platform: BinaryCLIPluginPlatform{Selector: selector, Bin: "hasura-plugin"},
+			FilePath: archivePath,
+		},
+		{
+			Name:     "Raw binary",
+			Platform: BinaryCLIPluginPlatform{Selector: selector, Bin: "hasura-plugin"},
+			FilePath: exe,
+		},
+		{
+			Name:     "Binary inside zip archive",
+			Platform: BinaryCLIPluginPlatform{Selector: selector, Bin: "hasura-plugin"},
+			FilePath: zipPath,
+		},
+		{
+			Name:        "Bin not executable in zip archive",
+			Platform:    BinaryCLIPluginPlatform{Selector: selector, Bin: "hasura-plugin"},
+			FilePath:    notExecutableZipPath,
+			ExpectError: true,
+		},
+		{
+			Name:        "Missing bin",
+			Platform:    BinaryCLIPluginPlatform{Selector: selector, Bin: "other-plugin"},
+			FilePath:    archivePath,
+			ExpectError: true,
+		},
+		{
+			Name:        "Wrong platform",
+			Platform:    BinaryCLIPluginPlatform{Selector: "windows-arm64", Bin: "hasura-plugin"},
+			FilePath:    archivePath,
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := VerifyCLIPluginBinary(tc.FilePath, tc.Platform)
+			if tc.ExpectError && err == nil {
+				t.Error("expected an error")
+			}
+			if !tc.ExpectError && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyCLIPluginTransform(t *testing.T) {
+	useTestAssetFolders(t)
+
+	sha := strings.Repeat("ab", 32)
+	cp := ndchub.ConnectorPackaging{Namespace: "hasura", Name: "foo", Version: "v1"}
+	writeTestConnectorMetadata(t, cp, fmt.Sprintf(testBinaryInlineMetadata, sha))
+	dataServerURL, _ := url.Parse("https://data.example.com/")
+
+	if err := ApplyCLIPluginTransform(&Run{}, dataServerURL, []ndchub.ConnectorPackaging{cp}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata struct {
+		PackagingDefinition struct {
+			DockerImage string `yaml:"dockerImage"`
+		} `yaml:"packagingDefinition"`
+		CLIPlugin struct {
+			Type      CLIPluginType             `yaml:"type"`
+			Platforms []BinaryCLIPluginPlatform `yaml:"platforms"`
+		} `yaml:"cliPlugin"`
+	}
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	// the rest of the definition survives the rewrite of the platforms
+	if metadata.CLIPlugin.Type != BinaryInline {
+		t.Errorf("expected the cli plugin type to be kept, got %q", metadata.CLIPlugin.Type)
+	}
+	if metadata.PackagingDefinition.DockerImage != "ghcr.io/hasura/foo:v1" {
+		t.Errorf("expected the packaging definition to be kept, got %q", metadata.PackagingDefinition.DockerImage)
+	}
+	expected := []BinaryCLIPluginPlatform{{
+		Selector: "linux-amd64",
+		URI:      "https://data.example.com/hasura/foo/v1/linux-amd64/plugin-linux.tar.gz",
+		SHA256:   sha,
+		Bin:      "plugin",
+	}}
+	if !reflect.DeepEqual(metadata.CLIPlugin.Platforms, expected) {
+		t.Errorf("expected platforms %+v, got %+v", expected, metadata.CLIPlugin.Platforms)
+	}
+}
+
+func TestExtractCLIPluginBinary(t *testing.T) {
+	useTestAssetFolders(t)
+	archivePath := writeTestTarGz(t, map[string]string{
+		"README.md":         "not the plugin",
+		"bin/hasura-plugin": "binary",
+	})
+	binarySHA := fmt.Sprintf("%x", sha256.Sum256([]byte("binary")))
+
+	tt := []struct {
+		Selector string
+		FileName string
+	}{
+		{Selector: "linux-amd64", FileName: "hasura-plugin"},
+		{Selector: "windows-amd64", FileName: "hasura-plugin.exe"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Selector, func(t *testing.T) {
+			binPath := cliPluginBinaryPath("hasura", "foo", "v1", tc.Selector, "hasura-plugin")
+			if filepath.Base(binPath) != tc.FileName {
+				t.Errorf("expected the binary to be stored as %s, got %s", tc.FileName, binPath)
+			}
+			if err := extractCLIPluginBinary(archivePath, binPath, tc.Selector, "hasura-plugin"); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(binPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "binary" {
+				t.Errorf("unexpected binary %q", data)
+			}
+			sum, err := os.ReadFile(binPath + ".sha256")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := binarySHA + "  " + tc.FileName + "\n"; string(sum) != expected {
+				t.Errorf("expected checksum file %q, got %q", expected, sum)
+			}
+		})
+	}
+
+	if err := extractCLIPluginBinary(archivePath, filepath.Join(t.TempDir(), "other"), "linux-amd64", "other-plugin"); err == nil {
+		t.Error("expected an error for a bin missing from the archive")
+	}
+}
+
+func TestApplyCLIPluginTransformExtractBinaries(t *testing.T) {
+	useTestAssetFolders(t)
+
+	archiveSHA := strings.Repeat("ab", 32)
+	cp := ndchub.ConnectorPackaging{Namespace: "hasura", Name: "foo", Version: "v1"}
+	writeTestConnectorMetadata(t, cp, fmt.Sprintf(`cliPlugin:
+  type: BinaryInline
+  platforms:
+    - selector: linux-amd64
+      uri: https://example.com/plugin-linux.tar.gz
+      sha256: %[1]s
+      bin: plugin
+    - selector: windows-amd64
+      uri: https://example.com/plugin-windows.zip
+      sha256: %[1]s
+      bin: plugin
+`, archiveSHA))
+	for selector, content := range map[string]string{"linux-amd64": "linux binary", "windows-amd64": "windows binary"} {
+		binPath := cliPluginBinaryPath(cp.Namespace, cp.Name, cp.Version, selector, "plugin")
+		if err := os.MkdirAll(filepath.Dir(binPath), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(binPath, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dataServerURL, _ := url.Parse("https://data.example.com/")
+
+	if err := ApplyCLIPluginTransform(&Run{}, dataServerURL, []ndchub.ConnectorPackaging{cp}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	cliPlugin, _, err := readBinaryInlineCLIPlugin(connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cliPlugin == nil {
+		t.Fatal("expected the cli plugin to stay a BinaryInline one")
+	}
+	expected := []BinaryCLIPluginPlatform{
+		{
+			Selector: "linux-amd64",
+			URI:      "https://data.example.com/hasura/foo/v1/linux-amd64/plugin",
+			SHA256:   fmt.Sprintf("%x", sha256.Sum256([]byte("linux binary"))),
+			Bin:      "plugin",
+		},
+		{
+			Selector: "windows-amd64",
+			URI:      "https://data.example.com/hasura/foo/v1/windows-amd64/plugin.exe",
+			SHA256:   fmt.Sprintf("%x", sha256.Sum256([]byte("windows binary"))),
+			Bin:      "plugin",
+		},
+	}
+	if !reflect.DeepEqual(cliPlugin.Platforms, expected) {
+		t.Errorf("expected platforms %+v, got %+v", expected, cliPlugin.Platforms)
+	}
+}
@@ -0,0 +1,335 @@
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+// Connector definitions are packaged according to https://github.com/hasura/ndc-hub/blob/main/rfcs/0011-cli-and-connector-packaging.md
+
+const (
+	PrebuiltDockerImage = "PrebuiltDockerImage"
+	ManagedDockerBuild  = "ManagedDockerBuild"
+)
+
+var (
+	knownCommands = []string{
+		"update",
+		"watch",
+		"printSchemaAndCapabilities",
+		"upgradeConfiguration",
+	}
+	knownDockerComposeWatchActions = []string{"sync", "rebuild", "sync+restart"}
+)
+
+// DefinitionError lists every problem found in the connector definition of a
+// connector version.
+type DefinitionError struct {
+	Namespace string
+	Name      string
+	Version   string
+	Problems  []string
+}
+
+func (e *DefinitionError) Error() string {
+	return fmt.Sprintf("invalid connector definition for %s/%s %s:\n  %s", e.Namespace, e.Name, e.Version, strings.Join(e.Problems, "\n  "))
+}
+
+// ValidateConnectorDefinitions checks every extracted connector definition
+// and reports the problems of all versions at once.
+func ValidateConnectorDefinitions(run *Run, connPkgs []ndchub.ConnectorPackaging) error {
+	var mu sync.Mutex
+	var errs []*DefinitionError
+
+	var validate errgroup.Group
+	for _, cp := range run.KeepGoing.Remaining(connPkgs) {
+		validate.Go(func() error {
+			problems := ValidateConnectorDefinition(extractedConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version))
+			if len(problems) == 0 {
+				return nil
+			}
+
+			err := &DefinitionError{
+				Namespace: cp.Namespace,
+				Name:      cp.Name,
+				Version:   cp.Version,
+				Problems:  problems,
+			}
+			if run.KeepGoing != nil {
+				run.KeepGoing.Add(StageValidate, cp, err)
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+			return nil
+		})
+	}
+	_ = validate.Wait()
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	var joined []error
+	for _, err := range errs {
+		joined = append(joined, err)
+	}
+	return errors.Join(joined...)
+}
+
+// ValidateConnectorDefinition checks the files of an extracted connector
+// definition and returns its problems, prefixed with the file and line they
+// were found at.
+func ValidateConnectorDefinition(definitionFolder string) []string {
+	metadataFile := filepath.Join(".hasura-connector", "connector-metadata.yaml")
+	data, err := os.ReadFile(filepath.Join(definitionFolder, metadataFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{fmt.Sprintf("missing required file %s", metadataFile)}
+		}
+		return []string{err.Error()}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []string{fmt.Sprintf("%s: %v", metadataFile, err)}
+	}
+
+	v := &definitionValidator{file: filepath.ToSlash(metadataFile)}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		v.addf(&doc, "expected a mapping at the top level")
+		return v.problems
+	}
+	root := doc.Content[0]
+
+	packagingType := v.packagingDefinition(root)
+	if packagingType == ManagedDockerBuild {
+		dockerfile := filepath.Join(".hasura-connector", "Dockerfile")
+		if _, err := os.Stat(filepath.Join(definitionFolder, dockerfile)); err != nil {
+			v.problems = append(v.problems, fmt.Sprintf("missing required file %s for a %s packaging definition", filepath.ToSlash(dockerfile), ManagedDockerBuild))
+		}
+	}
+	v.supportedEnvironmentVariables(root)
+	v.commands(root)
+	v.cliPlugin(root)
+	v.dockerComposeWatch(root)
+
+	return v.problems
+}
+
+type definitionValidator struct {
+	file     string
+	problems []string
+}
+
+func (v *definitionValidator) addf(n *yaml.Node, format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf("%s:%d: %s", v.file, n.Line, fmt.Sprintf(format, args...)))
+}
+
+// field returns the value of key in a mapping node, or nil.
+func field(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(n.Content); idx += 2 {
+		if n.Content[idx].Value == key {
+			return n.Content[idx+1]
+		}
+	}
+	return nil
+}
+
+func (v *definitionValidator) requireKind(n *yaml.Node, path string, kind yaml.Kind) bool {
+	if n.Kind == kind {
+		return true
+	}
+	names := map[yaml.Kind]string{
+		yaml.MappingNode:  "a mapping",
+		yaml.SequenceNode: "a list",
+		yaml.ScalarNode:   "a value",
+	}
+	v.addf(n, "%s must be %s", path, names[kind])
+	return false
+}
+
+// requireString checks that key of the mapping n is a non-empty string.
+func (v *definitionValidator) requireString(n *yaml.Node, path, key string) string {
+	value := field(n, key)
+	if value == nil {
+		v.addf(n, "%s.%s is required", path, key)
+		return ""
+	}
+	if value.Kind != yaml.ScalarNode || value.Value == "" {
+		v.addf(value, "%s.%s must be a non-empty string", path, key)
+		return ""
+	}
+	return value.Value
+}
+
+func (v *definitionValidator) packagingDefinition(root *yaml.Node) string {
+	pd := field(root, "packagingDefinition")
+	if pd == nil {
+		v.addf(root, "packagingDefinition is required")
+		return ""
+	}
+	if !v.requireKind(pd, "packagingDefinition", yaml.MappingNode) {
+		return ""
+	}
+
+	switch packagingType := v.requireString(pd, "packagingDefinition", "type"); packagingType {
+	case PrebuiltDockerImage:
+		v.requireString(pd, "packagingDefinition", "dockerImage")
+		return packagingType
+	case ManagedDockerBuild, "":
+		return packagingType
+	default:
+		v.addf(field(pd, "type"), "unknown packagingDefinition.type %q", packagingType)
+		return packagingType
+	}
+}
+
+func (v *definitionValidator) supportedEnvironmentVariables(root *yaml.Node) {
+	envVars := field(root, "supportedEnvironmentVariables")
+	if envVars == nil {
+		v.addf(root, "supportedEnvironmentVariables is required")
+		return
+	}
+	if !v.requireKind(envVars, "supportedEnvironmentVariables", yaml.SequenceNode) {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for idx, envVar := range envVars.Content {
+		path := fmt.Sprintf("supportedEnvironmentVariables[%d]", idx)
+		if !v.requireKind(envVar, path, yaml.MappingNode) {
+			continue
+		}
+		name := v.requireString(envVar, path, "name")
+		if name != "" && seen[name] {
+			v.addf(envVar, "%s: duplicate environment variable %s", path, name)
+		}
+		seen[name] = true
+		v.requireString(envVar, path, "description")
+		if required := field(envVar, "required"); required != nil && required.Tag != "!!bool" {
+			v.addf(required, "%s.required must be a boolean", path)
+		}
+	}
+}
+
+func (v *definitionValidator) commands(root *yaml.Node) {
+	commands := field(root, "commands")
+	if commands == nil {
+		v.addf(root, "commands is required")
+		return
+	}
+	if !v.requireKind(commands, "commands", yaml.MappingNode) {
+		return
+	}
+
+	for idx := 0; idx+1 < len(commands.Content); idx += 2 {
+		name, command := commands.Content[idx], commands.Content[idx+1]
+		path := "commands." + name.Value
+		if !containsString(knownCommands, name.Value) {
+			v.addf(name, "unknown command %s", path)
+			continue
+		}
+
+		switch command.Kind {
+		case yaml.ScalarNode:
+			if command.Value == "" {
+				v.addf(command, "%s must not be empty", path)
+			}
+		case yaml.MappingNode:
+			switch commandType := v.requireString(command, path, "type"); commandType {
+			case "Dockerized":
+				v.requireString(command, path, "dockerImage")
+				if args := field(command, "commandArgs"); args != nil {
+					v.requireKind(args, path+".commandArgs", yaml.SequenceNode)
+				}
+			case "ShellScript":
+				if field(command, "bash") == nil && field(command, "powershell") == nil {
+					v.addf(command, "%s needs a bash or powershell script", path)
+				}
+			case "":
+			default:
+				v.addf(field(command, "type"), "unknown %s.type %q", path, commandType)
+			}
+		default:
+			v.addf(command, "%s must be a script or a mapping", path)
+		}
+	}
+}
+
+func (v *definitionValidator) cliPlugin(root *yaml.Node) {
+	cliPlugin := field(root, "cliPlugin")
+	if cliPlugin == nil {
+		return
+	}
+	if !v.requireKind(cliPlugin, "cliPlugin", yaml.MappingNode) {
+		return
+	}
+
+	pluginType := string(Binary)
+	if t := field(cliPlugin, "type"); t != nil {
+		pluginType = t.Value
+	}
+
+	switch CLIPluginType(pluginType) {
+	case Binary:
+		v.requireString(cliPlugin, "cliPlugin", "name")
+		v.requireString(cliPlugin, "cliPlugin", "version")
+	case Docker:
+		v.requireString(cliPlugin, "cliPlugin", "dockerImage")
+	case BinaryInline:
+		var definition BinaryInlineCLIPluginDefinition
+		if err := cliPlugin.Decode(&definition); err != nil {
+			v.addf(cliPlugin, "cliPlugin: %v", err)
+			return
+		}
+		if err := definition.Validate(); err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				v.addf(cliPlugin, "cliPlugin: %s", line)
+			}
+		}
+	default:
+		v.addf(field(cliPlugin, "type"), "unknown cliPlugin.type %q", pluginType)
+	}
+}
+
+func (v *definitionValidator) dockerComposeWatch(root *yaml.Node) {
+	watch := field(root, "dockerComposeWatch")
+	if watch == nil {
+		return
+	}
+	if !v.requireKind(watch, "dockerComposeWatch", yaml.SequenceNode) {
+		return
+	}
+
+	for idx, rule := range watch.Content {
+		path := fmt.Sprintf("dockerComposeWatch[%d]", idx)
+		if !v.requireKind(rule, path, yaml.MappingNode) {
+			continue
+		}
+		v.requireString(rule, path, "path")
+		action := v.requireString(rule, path, "action")
+		if action != "" && !containsString(knownDockerComposeWatchActions, action) {
+			v.addf(field(rule, "action"), "unknown %s.action %q", path, action)
+		} else if action != "" && action != "rebuild" {
+			// synced files need a destination in the container
+			v.requireString(rule, path, "target")
+		}
+		if ignore := field(rule, "ignore"); ignore != nil {
+			v.requireKind(ignore, path+".ignore", yaml.SequenceNode)
+		}
+	}
+}
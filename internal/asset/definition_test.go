@@ -0,0 +1,115 @@
+package asset
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConnectorDefinition(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	tt := []struct {
+		Name             string
+		Metadata         string
+		Dockerfile       bool
+		ExpectedProblems []string
+	}{
+		{
+			Name: "Valid prebuilt image",
+			Metadata: `
+packagingDefinition:
+  type: PrebuiltDockerImage
+  dockerImage: ghcr.io/hasura/ndc-foo:v1.0.0
+supportedEnvironmentVariables:
+  - name: CONNECTION_URI
+    description: The connection string
+    required: true
+commands:
+  update: hasura-ndc-foo update
+  printSchemaAndCapabilities:
+    type: Dockerized
+    dockerImage: ghcr.io/hasura/ndc-foo:v1.0.0
+    commandArgs: [schema]
+cliPlugin:
+  type: BinaryInline
+  platforms:
+    - selector: linux-amd64
+      uri: https://github.com/hasura/ndc-foo/releases/download/v1.0.0/cli.tar.gz
+      sha256: ` + sha + `
+      bin: hasura-ndc-foo
+dockerComposeWatch:
+  - path: ./
+    target: /etc/connector
+    action: sync+restart
+`,
+		},
+		{
+			Name: "Managed build without a Dockerfile",
+			Metadata: `
+packagingDefinition:
+  type: ManagedDockerBuild
+supportedEnvironmentVariables: []
+commands: {}
+`,
+			ExpectedProblems: []string{
+				"missing required file .hasura-connector/Dockerfile",
+			},
+		},
+		{
+			Name: "Every problem is reported",
+			Metadata: `
+packagingDefinition:
+  type: PrebuiltDockerImage
+supportedEnvironmentVariables:
+  - name: CONNECTION_URI
+commands:
+  deploy: ./deploy.sh
+cliPlugin:
+  type: BinaryInline
+  platforms:
+    - selector: linux-x86_64
+      uri: https://example.com/cli
+      sha256: ` + sha + `
+      bin: hasura-ndc-foo
+dockerComposeWatch:
+  - path: ./
+    action: copy
+`,
+			ExpectedProblems: []string{
+				"connector-metadata.yaml:3: packagingDefinition.dockerImage is required",
+				"connector-metadata.yaml:5: supportedEnvironmentVariables[0].description is required",
+				"connector-metadata.yaml:7: unknown command commands.deploy",
+				`connector-metadata.yaml:9: cliPlugin: platforms[0]: unknown selector "linux-x86_64"`,
+				`connector-metadata.yaml:17: unknown dockerComposeWatch[0].action "copy"`,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			folder := t.TempDir()
+			metadataFolder := filepath.Join(folder, ".hasura-connector")
+			if err := os.MkdirAll(metadataFolder, 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(metadataFolder, "connector-metadata.yaml"), []byte(tc.Metadata), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			problems := ValidateConnectorDefinition(folder)
+			if len(problems) != len(tc.ExpectedProblems) {
+				t.Fatalf("expected %d problems, got %d:\n%s", len(tc.ExpectedProblems), len(problems), strings.Join(problems, "\n"))
+			}
+			for idx, expected := range tc.ExpectedProblems {
+				if !strings.Contains(problems[idx], expected) {
+					t.Errorf("expected problem %q, got %q", expected, problems[idx])
+				}
+			}
+		})
+	}
+
+	if problems := ValidateConnectorDefinition(t.TempDir()); len(problems) != 1 || !strings.Contains(problems[0], "missing required file") {
+		t.Errorf("expected a missing connector-metadata.yaml to be reported, got %v", problems)
+	}
+}
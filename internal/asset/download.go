@@ -4,30 +4,33 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"path"
 
 	"github.com/hasura/ddn-assets/internal/ndchub"
-	"golang.org/x/sync/errgroup"
+	"github.com/hasura/ddn-assets/internal/tracing"
 )
 
-func DownloadConnectorTarballs(connPkgs []ndchub.ConnectorPackaging) error {
-	var connectorTarball errgroup.Group
+func DownloadConnectorTarballs(run *Run, connPkgs []ndchub.ConnectorPackaging) error {
 	for _, cp := range connPkgs {
+		if err := checkURI(run, uriSource(cp, "connector-packaging.json uri"), cp.URI); err != nil {
+			if err = versionFailed(run, StageDownload, cp, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	return forEachVersion(run, StageDownload, connPkgs, func(span *tracing.Span, cp ndchub.ConnectorPackaging) error {
 		versionFolder := connectorVersionFolderForDownload(cp.Namespace, cp.Name, cp.Version)
 		err := os.MkdirAll(versionFolder, 0777)
 		if err != nil {
 			return fmt.Errorf("error creating folder: %s %w", versionFolder, err)
 		}
 
-		connectorTarball.Go(func() error {
-			tarballPath := connectorTarballDownloadPath(cp.Namespace, cp.Name, cp.Version)
-			return downloadFile(cp.URI, tarballPath, cp.Checksum.Value)
-		})
-	}
-
-	return connectorTarball.Wait()
+		tarballPath := connectorTarballDownloadPath(cp.Namespace, cp.Name, cp.Version)
+		return downloadFile(run, span, connectorVersionLogger(run, cp), cp.URI, tarballPath, cp.Checksum.Value)
+	})
 }
 
 func getSHAIfFileExists(path string) (string, error) {
@@ -47,44 +50,109 @@ func getSHAIfFileExists(path string) (string, error) {
 	return fmt.Sprintf("%x", checksum), nil
 }
 
-func downloadFile(uri, destPath, sha256checksum string) error {
-	var err error
+// downloadFile makes the artefact at uri available at destPath, from an
+// existing copy, the download cache, the mirror or the network, in a child
+// span of parent.
+func downloadFile(run *Run, parent *tracing.Span, log *slog.Logger, uri, destPath, sha256checksum string) (err error) {
+	log = log.With("uri", uri, "path", destPath)
+	span := run.Tracer.Start(parent, "download "+path.Base(uri), tracing.String("uri", uri), tracing.String("path", destPath))
+
 	defer func() {
+		span.End(err)
 		if err != nil {
-			fmt.Println("error while creating: ", destPath)
+			log.Error("error while creating file", "error", err)
 			return
 		}
 		sha, _ := getSHAIfFileExists(destPath)
-		fmt.Printf("file: %s (sha256: %s) \n", destPath, sha)
+		log.Debug("file ready", "sha256", sha)
 	}()
 
 	sha, _ := getSHAIfFileExists(destPath)
-	if sha == sha256checksum {
-		fmt.Println("checksum matched, so using an existing copy: ", destPath)
+	// without a checksum an existing copy is only used offline, where it is
+	// the one stored by fetch
+	if sha != "" && (sha == sha256checksum || sha256checksum == "" && run.Offline) {
+		log.Info("checksum matched, so using an existing copy")
+		run.progress().ArtefactResolved(ArtefactExisting)
+		span.SetAttributes(tracing.String("source", ArtefactExisting))
 		return nil
 	}
 
+	if run.Cache != nil {
+		var hit bool
+		hit, err = run.Cache.Get(sha256checksum, destPath)
+		if err != nil {
+			return err
+		}
+		if hit {
+			log.Info("found in download cache")
+			run.progress().ArtefactResolved(ArtefactCache)
+			span.SetAttributes(tracing.String("source", ArtefactCache))
+			return nil
+		}
+	}
+
+	if run.Offline {
+		err = copyFromMirror(run, uri, destPath, sha256checksum)
+		if err == nil {
+			run.progress().ArtefactResolved(ArtefactMirror)
+			span.SetAttributes(tracing.String("source", ArtefactMirror))
+		}
+		return err
+	}
+
+	log.Info("starting download")
+	body, err := run.fetchers().Open(uri)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// created once the artefact is found, so that a missing one leaves no
+	// empty file behind
 	outFile, err := os.Create(destPath)
 	if err != nil {
 		return err
 	}
 	defer outFile.Close()
 
-	log.Println("starting download: ", uri)
-	resp, err := http.Get(uri)
+	_, err = io.Copy(outFile, progressReader{body, run.progress()})
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	if err = outFile.Close(); err != nil {
+		return err
+	}
+
+	if err = verifyDownload(run, uri, destPath, sha256checksum); err != nil {
+		return err
+	}
+	run.progress().ArtefactResolved(ArtefactNetwork)
+	span.SetAttributes(tracing.String("source", ArtefactNetwork))
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("error downloading: status code %d", resp.StatusCode)
+	if run.Cache != nil {
+		if _, err = run.Cache.Put(destPath); err != nil {
+			return fmt.Errorf("error adding %s to the download cache: %w", destPath, err)
+		}
 	}
 
-	_, err = io.Copy(outFile, resp.Body)
+	return nil
+}
+
+// verifyDownload checks the file downloaded from uri to destPath against its
+// expected checksum, when there is one, and removes it on a mismatch so that
+// a corrupted download is not picked up as an existing copy by the next run.
+func verifyDownload(run *Run, uri, destPath, sha256checksum string) error {
+	if sha256checksum == "" {
+		return nil
+	}
+	sha, err := getSHAIfFileExists(destPath)
 	if err != nil {
 		return err
 	}
-
+	if sha != sha256checksum {
+		run.progress().ChecksumFailed(uri)
+		_ = os.Remove(destPath)
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", uri, sha256checksum, sha)
+	}
 	return nil
 }
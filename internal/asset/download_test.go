@@ -0,0 +1,51 @@
+package asset
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadChecksum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "connector definition")
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics()
+	run := &Run{Progress: metrics}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	destPath := filepath.Join(t.TempDir(), "connector-definition.tar.gz")
+	uri := server.URL + "/connector-definition.tar.gz"
+
+	err := downloadFile(run, nil, log, uri, destPath, fmt.Sprintf("%x", sha256.Sum256([]byte("something else"))))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(destPath); !os.IsNotExist(err) {
+		t.Errorf("expected the mismatching download to be removed, got %v", err)
+	}
+	if rendered := string(metrics.Render(false)); !strings.Contains(rendered, "ddn_assets_checksum_failures 1\n") {
+		t.Errorf("expected the checksum failure to be reported:\n%s", rendered)
+	}
+
+	err = downloadFile(run, nil, log, uri, destPath, fmt.Sprintf("%x", sha256.Sum256([]byte("connector definition"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "connector definition" {
+		t.Errorf("unexpected download %q", data)
+	}
+}
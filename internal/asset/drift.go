@@ -0,0 +1,199 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hasura/ddn-assets/internal/fetch"
+)
+
+// PublishedOutputs is the part of an outputs folder that drift is detected
+// on: its index.json and the checksums of its files.
+type PublishedOutputs struct {
+	Index *Index
+	// Sums is nil when the checksums of the files are not published.
+	Sums map[string]string
+}
+
+// ReadLocalOutputs reads the index.json and SHA256SUMS of a generated outputs
+// folder. The checksums are computed when there is no SHA256SUMS file.
+func ReadLocalOutputs(outputsFolder string) (*PublishedOutputs, error) {
+	index, err := ReadIndexJSON(filepath.Join(outputsFolder, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sums, err := readSHA256Sums(filepath.Join(outputsFolder, SHA256SumsFileName))
+	if os.IsNotExist(err) {
+		manifest, err := buildManifest(outputsFolder)
+		if err != nil {
+			return nil, err
+		}
+		sums = make(map[string]string)
+		for _, f := range manifest.Files {
+			sums[f.Path] = f.SHA256
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &PublishedOutputs{Index: index, Sums: sums}, nil
+}
+
+// FetchRemoteOutputs fetches the index.json and SHA256SUMS published under
+// baseURL, usually the data server the outputs folder is deployed to. When
+// index.json is not published, nothing is, and every local version is added.
+// When only SHA256SUMS is not published, the versions are still compared but
+// the checksums are unavailable.
+func FetchRemoteOutputs(run *Run, baseURL string) (*PublishedOutputs, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var index Index
+	err := fetchRemoteFile(run, baseURL+"/index.json", func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&index)
+	})
+	if fetch.IsNotFound(err) {
+		return &PublishedOutputs{Index: &Index{}, Sums: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sums map[string]string
+	err = fetchRemoteFile(run, baseURL+"/"+SHA256SumsFileName, func(r io.Reader) error {
+		var err error
+		sums, err = parseSHA256Sums(r, baseURL+"/"+SHA256SumsFileName)
+		return err
+	})
+	if fetch.IsNotFound(err) {
+		return &PublishedOutputs{Index: &index}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublishedOutputs{Index: &index, Sums: sums}, nil
+}
+
+func fetchRemoteFile(run *Run, uri string, read func(io.Reader) error) error {
+	body, err := run.fetchers().Open(uri)
+	if err != nil {
+		return fmt.Errorf("error fetching %s: %w", uri, err)
+	}
+	defer body.Close()
+	if err := read(body); err != nil {
+		return fmt.Errorf("error reading %s: %w", uri, err)
+	}
+	return nil
+}
+
+// DriftReport lists the connector versions, as namespace/name/version, that
+// differ between a local generation and the published outputs.
+type DriftReport struct {
+	// Added versions are generated locally but not published.
+	Added []string
+	// Removed versions are published but no longer generated locally.
+	Removed []string
+	// Changed versions are in both, with files that differ.
+	Changed []ChangedVersion
+	// ChecksumsUnavailable is set when the published checksums are not
+	// available, the files of the versions in both are then not compared.
+	ChecksumsUnavailable bool
+}
+
+type ChangedVersion struct {
+	Version string
+	// Files are relative to the connector version folder.
+	Files []string
+}
+
+func (r *DriftReport) OK() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+func (r *DriftReport) String() string {
+	var sb strings.Builder
+	for _, v := range r.Added {
+		fmt.Fprintf(&sb, "added: %s\n", v)
+	}
+	for _, v := range r.Removed {
+		fmt.Fprintf(&sb, "removed: %s\n", v)
+	}
+	for _, c := range r.Changed {
+		fmt.Fprintf(&sb, "changed: %s (%s)\n", c.Version, strings.Join(c.Files, ", "))
+	}
+	if r.ChecksumsUnavailable {
+		fmt.Fprintf(&sb, "checksums unavailable: %s is not published, changed files were not compared\n", SHA256SumsFileName)
+	}
+	return sb.String()
+}
+
+// versionFiles returns the checksums of the files of a connector version,
+// keyed by their path in the version folder. Signatures are left out, as
+// signing is not deterministic for every key type, and so are the provenance
+// and SBOM documents, which record the ddn-assets version that generated them
+// rather than what is published.
+func (o *PublishedOutputs) versionFiles(version string) map[string]string {
+	files := make(map[string]string)
+	for path, sha := range o.Sums {
+		rel, ok := strings.CutPrefix(path, version+"/")
+		if !ok || strings.HasSuffix(rel, ".sig") || rel == provenanceFileName || rel == sbomFileName {
+			continue
+		}
+		files[rel] = sha
+	}
+	return files
+}
+
+// CompareOutputs reports the connector versions that differ between a local
+// generation and the published outputs.
+func CompareOutputs(local, remote *PublishedOutputs) *DriftReport {
+	localVersions := indexVersions(local.Index)
+	remoteVersions := indexVersions(remote.Index)
+
+	report := DriftReport{ChecksumsUnavailable: remote.Sums == nil}
+	for v := range localVersions {
+		if !remoteVersions[v] {
+			report.Added = append(report.Added, v)
+			continue
+		}
+		if report.ChecksumsUnavailable {
+			continue
+		}
+
+		localFiles := local.versionFiles(v)
+		remoteFiles := remote.versionFiles(v)
+		var changed []string
+		for rel, sha := range localFiles {
+			if remoteFiles[rel] != sha {
+				changed = append(changed, rel)
+			}
+		}
+		for rel := range remoteFiles {
+			if _, ok := localFiles[rel]; !ok {
+				changed = append(changed, rel)
+			}
+		}
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			report.Changed = append(report.Changed, ChangedVersion{Version: v, Files: changed})
+		}
+	}
+	for v := range remoteVersions {
+		if !localVersions[v] {
+			report.Removed = append(report.Removed, v)
+		}
+	}
+
+	sort.Strings(report.Added)
+	sort.Strings(report.Removed)
+	sort.Slice(report.Changed, func(i, j int) bool {
+		return report.Changed[i].Version < report.Changed[j].Version
+	})
+	return &report
+}
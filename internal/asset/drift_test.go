@@ -0,0 +1,109 @@
+package asset
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareOutputs(t *testing.T) {
+	remote := http.NewServeMux()
+	remote.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"connector_versions": {"hasura/foo": ["v1", "v2"], "hasura/bar": ["v1"]}}`))
+	})
+	remote.HandleFunc("/SHA256SUMS", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("" +
+			"aaaa  hasura/foo/v1/connector-definition.tar.gz\n" +
+			"1111  hasura/foo/v1/connector-definition.tar.gz.sig\n" +
+			"bbbb  hasura/foo/v2/connector-definition.tar.gz\n" +
+			"cccc  hasura/foo/v2/cli-plugins/linux-amd64/plugin.tar.gz\n" +
+			"dddd  hasura/bar/v1/connector-definition.tar.gz\n"))
+	})
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	outputs := t.TempDir()
+	files := map[string]string{
+		"index.json": `{"connector_versions": {"hasura/foo": ["v1", "v2", "v3"]}}`,
+		SHA256SumsFileName: "" +
+			"aaaa  hasura/foo/v1/connector-definition.tar.gz\n" +
+			"2222  hasura/foo/v1/connector-definition.tar.gz.sig\n" +
+			"3333  hasura/foo/v1/provenance.intoto.json\n" +
+			"eeee  hasura/foo/v2/connector-definition.tar.gz\n" +
+			"ffff  hasura/foo/v3/connector-definition.tar.gz\n",
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(outputs, rel), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	local, err := ReadLocalOutputs(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := FetchRemoteOutputs(&Run{}, server.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := CompareOutputs(local, published)
+	expected := &DriftReport{
+		Added:   []string{"hasura/foo/v3"},
+		Removed: []string{"hasura/bar/v1"},
+		Changed: []ChangedVersion{
+			{Version: "hasura/foo/v2", Files: []string{"cli-plugins/linux-amd64/plugin.tar.gz", "connector-definition.tar.gz"}},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected report\n got: %+v\nwant: %+v", report, expected)
+	}
+
+	if report := CompareOutputs(local, local); !report.OK() {
+		t.Errorf("expected no drift against itself, got:\n%s", report)
+	}
+
+	if report.ChecksumsUnavailable {
+		t.Errorf("expected the published checksums to be compared")
+	}
+
+	unpublished, err := FetchRemoteOutputs(&Run{}, server.URL+"/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = CompareOutputs(local, unpublished)
+	if !reflect.DeepEqual(report.Added, []string{"hasura/foo/v1", "hasura/foo/v2", "hasura/foo/v3"}) || len(report.Changed) != 0 {
+		t.Errorf("expected every version to be added to unpublished outputs, got:\n%s", report)
+	}
+}
+
+func TestCompareOutputsWithoutSums(t *testing.T) {
+	remote := http.NewServeMux()
+	remote.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"connector_versions": {"hasura/foo": ["v1", "v2"], "hasura/bar": ["v1"]}}`))
+	})
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	local := &PublishedOutputs{
+		Index: &Index{ConnectorVersions: map[string][]string{"hasura/foo": {"v1", "v2", "v3"}}},
+		Sums:  map[string]string{"hasura/foo/v2/connector-definition.tar.gz": "eeee"},
+	}
+	published, err := FetchRemoteOutputs(&Run{}, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := CompareOutputs(local, published)
+	expected := &DriftReport{
+		Added:                []string{"hasura/foo/v3"},
+		Removed:              []string{"hasura/bar/v1"},
+		ChecksumsUnavailable: true,
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected report\n got: %+v\nwant: %+v", report, expected)
+	}
+}
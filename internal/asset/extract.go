@@ -5,78 +5,134 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hasura/ddn-assets/internal/ndchub"
-	"golang.org/x/sync/errgroup"
+	"github.com/hasura/ddn-assets/internal/tracing"
 )
 
-func ExtractConnectorTarballs(connPkgs []ndchub.ConnectorPackaging) error {
-	var extract errgroup.Group
-	for _, cp := range connPkgs {
-		extract.Go(func() error {
-			srcTarball := connectorTarballDownloadPath(cp.Namespace, cp.Name, cp.Version)
-			file, err := os.Open(srcTarball)
-			if err != nil {
-				return fmt.Errorf("could not open file: %v", err)
-			}
-			defer file.Close()
+// ExtractLimits bounds what extracting a connector tarball can write to disk,
+// so that a malicious or broken tarball can not fill the disk. A zero value
+// disables the limit.
+type ExtractLimits struct {
+	MaxTotalSize int64
+	MaxFileSize  int64
+	MaxEntries   int
+	MaxPathDepth int
+}
 
-			destFolder := extractedConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version)
-			err = os.MkdirAll(destFolder, 0777)
-			if err != nil {
-				return fmt.Errorf("error creating folder: %s %w", destFolder, err)
-			}
+var DefaultExtractLimits = ExtractLimits{
+	MaxTotalSize: 256 * 1024 * 1024,
+	MaxFileSize:  64 * 1024 * 1024,
+	MaxEntries:   10000,
+	MaxPathDepth: 32,
+}
+
+func ExtractConnectorTarballs(run *Run, connPkgs []ndchub.ConnectorPackaging) error {
+	return forEachVersion(run, StageExtract, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		srcTarball := connectorTarballDownloadPath(cp.Namespace, cp.Name, cp.Version)
+		destFolder := extractedConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version)
+		err := extractTarGz(connectorVersionLogger(run, cp), srcTarball, destFolder, run.extractLimits())
+		if err != nil {
+			return fmt.Errorf("error extracting connector tarball of %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+		}
+		return nil
+	})
+}
+
+func extractTarGz(log *slog.Logger, srcTarball, destFolder string, limits ExtractLimits) error {
+	file, err := os.Open(srcTarball)
+	if err != nil {
+		return fmt.Errorf("could not open file: %v", err)
+	}
+	defer file.Close()
+
+	err = os.MkdirAll(destFolder, 0777)
+	if err != nil {
+		return fmt.Errorf("error creating folder: %s %w", destFolder, err)
+	}
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("could not create gzip reader: %v", err)
+	}
+	defer gzReader.Close()
+	tarReader := tar.NewReader(gzReader)
+
+	var entries int
+	var totalSize int64
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break // end of archive
+		}
+		if err != nil {
+			return fmt.Errorf("could not read tar header: %v", err)
+		}
 
-			gzReader, err := gzip.NewReader(file)
-			if err != nil {
-				return fmt.Errorf("could not create gzip reader: %v", err)
+		entries++
+		if limits.MaxEntries > 0 && entries > limits.MaxEntries {
+			return fmt.Errorf("entry count limit of %d exceeded", limits.MaxEntries)
+		}
+
+		name := filepath.Clean(header.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("entry %s is outside of the archive root", header.Name)
+		}
+		if depth := len(strings.Split(name, string(filepath.Separator))); limits.MaxPathDepth > 0 && depth > limits.MaxPathDepth {
+			return fmt.Errorf("path depth limit of %d exceeded by %s", limits.MaxPathDepth, header.Name)
+		}
+
+		outPath := filepath.Join(destFolder, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			// Create the directory
+			if err := os.MkdirAll(outPath, os.FileMode(header.Mode)); err != nil {
+				return fmt.Errorf("could not create directory: %v", err)
 			}
-			defer gzReader.Close()
-			tarReader := tar.NewReader(gzReader)
-
-			for {
-				header, err := tarReader.Next()
-				if err == io.EOF {
-					break // end of archive
-				}
-				if err != nil {
-					return fmt.Errorf("could not read tar header: %v", err)
-				}
-
-				outPath := filepath.Join(destFolder, header.Name)
-				switch header.Typeflag {
-				case tar.TypeDir:
-					// Create the directory
-					if err := os.MkdirAll(outPath, os.FileMode(header.Mode)); err != nil {
-						return fmt.Errorf("could not create directory: %v", err)
-					}
-				case tar.TypeReg:
-					// Create the file
-					outFile, err := os.Create(outPath)
-					if err != nil {
-						return fmt.Errorf("could not create file: %v", err)
-					}
-					defer outFile.Close()
-
-					// Copy the file content
-					if _, err := io.Copy(outFile, tarReader); err != nil {
-						return fmt.Errorf("could not write file content: %v", err)
-					}
-
-					// Set file permissions
-					if err := os.Chmod(outPath, os.FileMode(header.Mode)); err != nil {
-						return fmt.Errorf("could not set file permissions: %v", err)
-					}
-				default:
-					// Handle other types if needed
-					fmt.Printf("Skipping unsupported file type: %c in %s\n", header.Typeflag, header.Name)
-				}
+		case tar.TypeReg:
+			if limits.MaxFileSize > 0 && header.Size > limits.MaxFileSize {
+				return fmt.Errorf("file size limit of %d bytes exceeded by %s (%d bytes)", limits.MaxFileSize, header.Name, header.Size)
 			}
+			totalSize += header.Size
+			if limits.MaxTotalSize > 0 && totalSize > limits.MaxTotalSize {
+				return fmt.Errorf("total size limit of %d bytes exceeded at %s", limits.MaxTotalSize, header.Name)
+			}
+
+			if err := extractTarFile(tarReader, outPath, os.FileMode(header.Mode)); err != nil {
+				return err
+			}
+		default:
+			// Handle other types if needed
+			log.Warn("skipping unsupported file type", "type", string(header.Typeflag), "entry", header.Name)
+		}
+	}
+
+	return nil
+}
+
+func extractTarFile(r io.Reader, outPath string, mode os.FileMode) error {
+	// Create the file, along with its folder when the archive has no entry for it
+	if err := os.MkdirAll(filepath.Dir(outPath), 0777); err != nil {
+		return fmt.Errorf("could not create directory: %v", err)
+	}
+	outFile, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("could not create file: %v", err)
+	}
+	defer outFile.Close()
+
+	// Copy the file content
+	if _, err := io.Copy(outFile, r); err != nil {
+		return fmt.Errorf("could not write file content: %v", err)
+	}
 
-			return nil
-		})
+	// Set file permissions
+	if err := os.Chmod(outPath, mode); err != nil {
+		return fmt.Errorf("could not set file permissions: %v", err)
 	}
-	return extract.Wait()
+	return nil
 }
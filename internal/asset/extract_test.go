@@ -0,0 +1,117 @@
+package asset
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestTarGz(t *testing.T, files map[string]string) string {
+	t.Helper()
+	tarballPath := filepath.Join(t.TempDir(), "connector-definition.tar.gz")
+	file, err := os.Create(tarballPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gzWriter := gzip.NewWriter(file)
+	defer gzWriter.Close()
+	tarWriter := tar.NewWriter(gzWriter)
+	defer tarWriter.Close()
+
+	for name, content := range files {
+		// files under bin/ are executables
+		mode := int64(0644)
+		if strings.HasPrefix(name, "bin/") {
+			mode = 0755
+		}
+		err := tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     mode,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tarballPath
+}
+
+func TestExtractTarGzLimits(t *testing.T) {
+	limits := ExtractLimits{MaxTotalSize: 100, MaxFileSize: 60, MaxEntries: 3, MaxPathDepth: 3}
+
+	tt := []struct {
+		Name          string
+		Files         map[string]string
+		ExpectedError string
+	}{
+		{
+			Name: "Within limits",
+			Files: map[string]string{
+				"connector-metadata.yaml": strings.Repeat("a", 50),
+				"docker/Dockerfile":       strings.Repeat("b", 40),
+			},
+		},
+		{
+			Name: "Too many entries",
+			Files: map[string]string{
+				"a": "a", "b": "b", "c": "c", "d": "d",
+			},
+			ExpectedError: "entry count limit of 3",
+		},
+		{
+			Name: "File too large",
+			Files: map[string]string{
+				"large": strings.Repeat("a", 61),
+			},
+			ExpectedError: "file size limit of 60 bytes",
+		},
+		{
+			Name: "Total too large",
+			Files: map[string]string{
+				"first":  strings.Repeat("a", 60),
+				"second": strings.Repeat("b", 60),
+			},
+			ExpectedError: "total size limit of 100 bytes",
+		},
+		{
+			Name: "Path too deep",
+			Files: map[string]string{
+				"a/b/c/d": "deep",
+			},
+			ExpectedError: "path depth limit of 3",
+		},
+		{
+			Name: "Path outside of the archive",
+			Files: map[string]string{
+				"../escape": "escape",
+			},
+			ExpectedError: "outside of the archive root",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			tarballPath := writeTestTarGz(t, tc.Files)
+			err := extractTarGz(slog.Default(), tarballPath, filepath.Join(t.TempDir(), "extract"), limits)
+			if tc.ExpectedError == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+				t.Errorf("expected error containing %q, got %v", tc.ExpectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+// VersionFailure is the failure of a connector version in a stage.
+type VersionFailure struct {
+	Namespace string
+	Name      string
+	Version   string
+	Stage     string
+	Err       error
+}
+
+func (f VersionFailure) key() string {
+	return fmt.Sprintf("%s/%s/%s", f.Namespace, f.Name, f.Version)
+}
+
+// Failures are the connector versions that failed, each one in the first
+// stage it failed in.
+type Failures struct {
+	mu       sync.Mutex
+	failures map[string]VersionFailure
+	// written are the output files written by this run, by connector version.
+	written map[string][]string
+	// backups hold the files of earlier runs that this run overwrites, by
+	// path, in backupDir.
+	backups   map[string]string
+	backupDir string
+}
+
+// Add records the failure of a connector version, unless it already failed in
+// an earlier stage.
+func (f *Failures) Add(stage string, cp ndchub.ConnectorPackaging, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	failure := VersionFailure{
+		Namespace: cp.Namespace,
+		Name:      cp.Name,
+		Version:   cp.Version,
+		Stage:     stage,
+		Err:       err,
+	}
+	if f.failures == nil {
+		f.failures = make(map[string]VersionFailure)
+	}
+	if _, ok := f.failures[failure.key()]; !ok {
+		f.failures[failure.key()] = failure
+	}
+}
+
+// Failed reports whether the connector version namespace/name/version failed.
+func (f *Failures) Failed(key string) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.failures[key]
+	return ok
+}
+
+// Wrote records that an output file of a connector version is about to be
+// written by this run, for RemoveOutputs to undo it if the version fails. It
+// is called before the file is written, so that a copy of the file an earlier
+// run published there is kept to be restored.
+func (f *Failures) Wrote(cp ndchub.ConnectorPackaging, path string) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.written == nil {
+		f.written = make(map[string][]string)
+		f.backups = make(map[string]string)
+	}
+	key := fmt.Sprintf("%s/%s/%s", cp.Namespace, cp.Name, cp.Version)
+	f.written[key] = append(f.written[key], path)
+
+	if _, ok := f.backups[path]; ok {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if f.backupDir == "" {
+		if f.backupDir, err = os.MkdirTemp("", "ddn-assets-backups"); err != nil {
+			return err
+		}
+	}
+	backupPath := filepath.Join(f.backupDir, fmt.Sprint(len(f.backups)))
+	if err := copyFile(path, backupPath); err != nil {
+		return fmt.Errorf("error keeping a copy of %s: %w", path, err)
+	}
+	if err := os.Chmod(backupPath, info.Mode()); err != nil {
+		return err
+	}
+	f.backups[path] = backupPath
+	return nil
+}
+
+// Remaining returns the connector versions that did not fail, for the next
+// stages to process.
+func (f *Failures) Remaining(connPkgs []ndchub.ConnectorPackaging) []ndchub.ConnectorPackaging {
+	if f == nil {
+		return connPkgs
+	}
+	var remaining []ndchub.ConnectorPackaging
+	for _, cp := range connPkgs {
+		if !f.Failed(fmt.Sprintf("%s/%s/%s", cp.Namespace, cp.Name, cp.Version)) {
+			remaining = append(remaining, cp)
+		}
+	}
+	return remaining
+}
+
+// List returns the failures sorted by connector version.
+func (f *Failures) List() []VersionFailure {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []VersionFailure
+	for _, failure := range f.failures {
+		list = append(list, failure)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Namespace != list[j].Namespace || list[i].Name != list[j].Name {
+			return list[i].Namespace+"/"+list[i].Name < list[j].Namespace+"/"+list[j].Name
+		}
+		return compareVersions(list[i].Version, list[j].Version) < 0
+	})
+	return list
+}
+
+// ExcludeFromIndex removes the failed connector versions from an index. A
+// connector whose latest version failed gets its newest version that neither
+// failed nor is yanked instead, preferring stable versions, and a connector
+// whose versions all failed is removed altogether. Connectors that had no
+// versions in the index to begin with are kept as they are.
+func (f *Failures) ExcludeFromIndex(index *Index) {
+	if f == nil {
+		return
+	}
+	failedConnectors := make(map[string]bool)
+	for slug, versions := range index.ConnectorVersions {
+		remaining := []string{}
+		for _, v := range versions {
+			key := fmt.Sprintf("%s/%s", slug, v)
+			if f.Failed(key) {
+				delete(index.Provenance, key)
+				delete(index.VersionStatus, key)
+				continue
+			}
+			remaining = append(remaining, v)
+		}
+		if len(remaining) == 0 && len(versions) > 0 {
+			delete(index.ConnectorVersions, slug)
+			failedConnectors[slug] = true
+			continue
+		}
+		index.ConnectorVersions[slug] = remaining
+	}
+
+	connectors := []Connector{}
+	for _, c := range index.Connectors {
+		slug := fmt.Sprintf("%s/%s", c.Namespace, c.Name)
+		if failedConnectors[slug] {
+			continue
+		}
+		if !f.Failed(fmt.Sprintf("%s/%s", slug, c.LatestVersion)) {
+			connectors = append(connectors, c)
+			continue
+		}
+		var candidates []string
+		for _, v := range index.ConnectorVersions[slug] {
+			if index.VersionStatus[fmt.Sprintf("%s/%s", slug, v)].Status != ndchub.VersionStatusYanked {
+				candidates = append(candidates, v)
+			}
+		}
+		c.LatestVersion = newestVersion(candidates)
+		connectors = append(connectors, c)
+	}
+	index.Connectors = connectors
+	index.TotalConnectors = len(connectors)
+}
+
+// RemoveOutputs undoes the output files this run wrote for the failed
+// connector versions, so that what they left behind is neither signed nor
+// listed in the outputs manifest. The files of earlier runs that were
+// overwritten are restored, the others removed.
+func (f *Failures) RemoveOutputs() error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer func() {
+		if f.backupDir != "" {
+			_ = os.RemoveAll(f.backupDir)
+			f.backupDir = ""
+			f.backups = make(map[string]string)
+		}
+	}()
+
+	for _, failure := range f.failures {
+		for _, path := range f.written[failure.key()] {
+			if backupPath, ok := f.backups[path]; ok {
+				if err := restoreFile(backupPath, path); err != nil {
+					return fmt.Errorf("error restoring %s: %w", path, err)
+				}
+				continue
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("error removing %s: %w", path, err)
+			}
+			removeEmptyFolders(filepath.Dir(path), OutputFolderPath)
+		}
+	}
+	return nil
+}
+
+// restoreFile copies a backup back to path, along with its mode.
+func restoreFile(backupPath, path string) error {
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	if err := copyFile(backupPath, path); err != nil {
+		return err
+	}
+	return os.Chmod(path, info.Mode())
+}
+
+// removeEmptyFolders removes folder and its parents up to root, for as long
+// as they are empty.
+func removeEmptyFolders(folder, root string) {
+	for folder != root && strings.HasPrefix(folder, root+string(filepath.Separator)) {
+		// os.Remove fails on folders that are not empty
+		if err := os.Remove(folder); err != nil {
+			return
+		}
+		folder = filepath.Dir(folder)
+	}
+}
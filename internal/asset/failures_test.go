@@ -0,0 +1,138 @@
+package asset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
+)
+
+func TestKeepGoing(t *testing.T) {
+	run := &Run{KeepGoing: &Failures{}}
+
+	connPkgs := []ndchub.ConnectorPackaging{
+		{Namespace: "hasura", Name: "foo", Version: "v1.0.0"},
+		{Namespace: "hasura", Name: "foo", Version: "v1.1.0"},
+		{Namespace: "hasura", Name: "foo", Version: "v1.2.0"},
+		{Namespace: "hasura", Name: "bar", Version: "v0.1.0"},
+	}
+	err := forEachVersion(run, StageDownload, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		if cp.Version == "v1.2.0" || cp.Name == "bar" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected the failures to be recorded, got %s", err)
+	}
+
+	var processed []string
+	err = forEachVersion(run, StageExtract, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		if cp.Version == "v1.1.0" {
+			return errors.New("bad tarball")
+		}
+		processed = append(processed, cp.Version)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(processed, []string{"v1.0.0"}) {
+		t.Errorf("expected the failed versions to be skipped, processed %v", processed)
+	}
+
+	var summary []string
+	for _, f := range run.KeepGoing.List() {
+		summary = append(summary, f.Namespace+"/"+f.Name+" "+f.Version+" "+f.Stage+": "+f.Err.Error())
+	}
+	expected := []string{
+		"hasura/bar v0.1.0 download: boom",
+		"hasura/foo v1.1.0 extract: bad tarball",
+		"hasura/foo v1.2.0 download: boom",
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("expected failures %v, got %v", expected, summary)
+	}
+
+	// acme/baz has a metadata.json without any connector-packaging.json
+	index := &Index{
+		TotalConnectors: 3,
+		Connectors: []Connector{
+			{Namespace: "hasura", Name: "foo", LatestVersion: "v1.2.0"},
+			{Namespace: "hasura", Name: "bar", LatestVersion: "v0.1.0"},
+			{Namespace: "acme", Name: "baz"},
+		},
+		ConnectorVersions: map[string][]string{
+			"hasura/foo": {"v1.0.0", "v1.1.0", "v1.2.0"},
+			"hasura/bar": {"v0.1.0"},
+		},
+		Provenance: map[string]string{
+			"hasura/foo/v1.0.0": "hasura/foo/v1.0.0/provenance.intoto.json",
+			"hasura/foo/v1.2.0": "hasura/foo/v1.2.0/provenance.intoto.json",
+		},
+	}
+	run.KeepGoing.ExcludeFromIndex(index)
+	expectedIndex := &Index{
+		TotalConnectors: 2,
+		Connectors: []Connector{
+			{Namespace: "hasura", Name: "foo", LatestVersion: "v1.0.0"},
+			{Namespace: "acme", Name: "baz"},
+		},
+		ConnectorVersions: map[string][]string{
+			"hasura/foo": {"v1.0.0"},
+		},
+		Provenance: map[string]string{
+			"hasura/foo/v1.0.0": "hasura/foo/v1.0.0/provenance.intoto.json",
+		},
+	}
+	if !reflect.DeepEqual(index, expectedIndex) {
+		t.Errorf("expected index %+v, got %+v", expectedIndex, index)
+	}
+}
+
+func TestRemoveOutputs(t *testing.T) {
+	useTestAssetFolders(t)
+	failures := &Failures{}
+
+	cp := ndchub.ConnectorPackaging{Namespace: "hasura", Name: "foo", Version: "v1.0.0"}
+	folder := outputConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version)
+	if err := os.MkdirAll(filepath.Join(folder, "cli-plugins", "linux-amd64"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	// published by an earlier run, and overwritten by this one
+	published := filepath.Join(folder, connectorDefinitionTarballName)
+	if err := os.WriteFile(published, []byte("published"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := failures.Wrote(cp, published); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(published, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// written by this run only
+	written := filepath.Join(folder, "cli-plugins", "linux-amd64", "plugin")
+	for _, path := range []string{written, filepath.Join(folder, sbomFileName)} {
+		if err := failures.Wrote(cp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(written, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	failures.Add(StageSBOM, cp, errors.New("boom"))
+
+	if err := failures.RemoveOutputs(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(published); err != nil || string(data) != "published" {
+		t.Errorf("expected the output of the earlier run to be restored, got %q %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "cli-plugins")); !os.IsNotExist(err) {
+		t.Errorf("expected the files written by the run and their empty folders to be removed, got %v", err)
+	}
+}
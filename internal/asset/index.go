@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 const (
@@ -16,8 +18,12 @@ var (
 	ExtractsFolderPath  = filepath.Join(AssetFolderPath, "extracts")
 	OutputFolderPath    = filepath.Join(AssetFolderPath, "outputs")
 	IndexJsonPath       = filepath.Join(OutputFolderPath, "index.json")
+	// ConnectorsJsonPath lists the connectors only, their versions are in
+	// the index.json of each connector folder.
+	ConnectorsJsonPath = filepath.Join(OutputFolderPath, "connectors.json")
 
 	connectorDefinitionTarballName = "connector-definition.tar.gz"
+	provenanceFileName             = "provenance.intoto.json"
 )
 
 func CreateAssetFolders() error {
@@ -45,10 +51,18 @@ func connectorTarballDownloadPath(namespace, name, version string) string {
 	return filepath.Join(connectorVersionFolderForDownload(namespace, name, version), connectorDefinitionTarballName)
 }
 
+func connectorSignatureDownloadPath(namespace, name, version string) string {
+	return connectorTarballDownloadPath(namespace, name, version) + ".sig"
+}
+
 func extractedConnectorVersionFolder(namespace, name, version string) string {
 	return filepath.Join(ExtractsFolderPath, namespace, name, version)
 }
 
+func connectorMetadataFilePath(namespace, name, version string) string {
+	return filepath.Join(extractedConnectorVersionFolder(namespace, name, version), ".hasura-connector", "connector-metadata.yaml")
+}
+
 func outputConnectorVersionFolder(namespace, name, version string) string {
 	return filepath.Join(OutputFolderPath, namespace, name, version)
 }
@@ -61,10 +75,27 @@ func cliPluginFolder(namespace, name, version string) string {
 	return filepath.Join(outputConnectorVersionFolder(namespace, name, version), "cli-plugins")
 }
 
+func cliPluginDownloadFolder(namespace, name, version string) string {
+	return filepath.Join(connectorVersionFolderForDownload(namespace, name, version), "cli-plugins")
+}
+
+func cliPluginBinaryPath(namespace, name, version, selector, bin string) string {
+	if strings.HasPrefix(selector, "windows-") && !strings.HasSuffix(bin, ".exe") {
+		bin += ".exe"
+	}
+	return filepath.Join(cliPluginFolder(namespace, name, version), selector, bin)
+}
+
 type Index struct {
 	TotalConnectors   int                 `json:"total_connectors"`
 	Connectors        []Connector         `json:"connectors"`
 	ConnectorVersions map[string][]string `json:"connector_versions"`
+	// Provenance maps namespace/name/version to the provenance document of
+	// the connector version, relative to the outputs folder.
+	Provenance map[string]string `json:"provenance,omitempty"`
+	// VersionStatus maps namespace/name/version to the status of the
+	// connector versions that are deprecated or yanked.
+	VersionStatus map[string]VersionStatus `json:"version_status,omitempty"`
 }
 
 type Connector struct {
@@ -73,16 +104,152 @@ type Connector struct {
 	LatestVersion string `json:"latest_version"`
 }
 
-func WriteIndexJSON(index *Index) error {
-	indexJson, err := json.MarshalIndent(index, "", "  ")
+func connectorIndexJsonPath(namespace, name string) string {
+	return filepath.Join(OutputFolderPath, namespace, name, "index.json")
+}
+
+// ConnectorsIndex is the top-level index of the sharded layout, clients
+// resolve a connector through its own index.json.
+type ConnectorsIndex struct {
+	TotalConnectors int              `json:"total_connectors"`
+	Connectors      []IndexConnector `json:"connectors"`
+}
+
+type IndexConnector struct {
+	Connector
+	// Index is the index.json of the connector, relative to the outputs
+	// folder.
+	Index string `json:"index"`
+}
+
+// ConnectorIndex lists the versions of a single connector, with the same
+// information the monolithic index.json has about them.
+type ConnectorIndex struct {
+	Connector
+	Versions []string `json:"versions"`
+	// Provenance and VersionStatus are keyed by version.
+	Provenance    map[string]string        `json:"provenance,omitempty"`
+	VersionStatus map[string]VersionStatus `json:"version_status,omitempty"`
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		return fmt.Errorf("error while marshalling index json")
+		return fmt.Errorf("error while marshalling %s: %w", filepath.Base(path), err)
 	}
 
-	err = os.WriteFile(IndexJsonPath, indexJson, 0644)
+	err = os.WriteFile(path, data, 0644)
 	if err != nil {
-		return fmt.Errorf("error writing %s: %s", IndexJsonPath, err)
+		return fmt.Errorf("error writing %s: %s", path, err)
 	}
 
 	return nil
 }
+
+// writeIndexDocument writes an index file after checking it against its
+// schema, so that an index clients can not read is never published.
+func writeIndexDocument(schema *Schema, path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while marshalling %s: %w", filepath.Base(path), err)
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if problems := schema.Validate(doc); len(problems) > 0 {
+		return fmt.Errorf("refusing to write %s, it does not match %s:\n  %s", path, schema.ID, strings.Join(problems, "\n  "))
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// WriteIndexJSON writes the monolithic index.json along with the sharded
+// layout: connectors.json and an index.json per connector. Every file is
+// checked against its schema, and the schemas are written to the outputs
+// folder too.
+func WriteIndexJSON(index *Index) error {
+	schemas, err := IndexSchemas()
+	if err != nil {
+		return err
+	}
+
+	// the schemas have arrays and objects, never null
+	if index.Connectors == nil {
+		index.Connectors = []Connector{}
+	}
+	if index.ConnectorVersions == nil {
+		index.ConnectorVersions = map[string][]string{}
+	}
+	for slug, versions := range index.ConnectorVersions {
+		if versions == nil {
+			index.ConnectorVersions[slug] = []string{}
+		}
+	}
+	if err := writeIndexDocument(schemas[schemaFileName("index")], IndexJsonPath, index); err != nil {
+		return err
+	}
+
+	connectors := make(map[string]Connector)
+	for slug := range index.ConnectorVersions {
+		namespace, name, _ := strings.Cut(slug, "/")
+		connectors[slug] = Connector{Namespace: namespace, Name: name}
+	}
+	for _, c := range index.Connectors {
+		connectors[fmt.Sprintf("%s/%s", c.Namespace, c.Name)] = c
+	}
+
+	top := ConnectorsIndex{Connectors: []IndexConnector{}}
+	for slug, c := range connectors {
+		connectorIndex := ConnectorIndex{
+			Connector: c,
+			Versions:  index.ConnectorVersions[slug],
+		}
+		if connectorIndex.Versions == nil {
+			connectorIndex.Versions = []string{}
+		}
+		for _, v := range connectorIndex.Versions {
+			key := fmt.Sprintf("%s/%s", slug, v)
+			if p, ok := index.Provenance[key]; ok {
+				if connectorIndex.Provenance == nil {
+					connectorIndex.Provenance = make(map[string]string)
+				}
+				connectorIndex.Provenance[v] = p
+			}
+			if status, ok := index.VersionStatus[key]; ok {
+				if connectorIndex.VersionStatus == nil {
+					connectorIndex.VersionStatus = make(map[string]VersionStatus)
+				}
+				connectorIndex.VersionStatus[v] = status
+			}
+		}
+
+		path := connectorIndexJsonPath(c.Namespace, c.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return fmt.Errorf("error creating folder: %s %w", filepath.Dir(path), err)
+		}
+		if err := writeIndexDocument(schemas[schemaFileName("connector-index")], path, connectorIndex); err != nil {
+			return err
+		}
+
+		top.Connectors = append(top.Connectors, IndexConnector{
+			Connector: c,
+			Index:     fmt.Sprintf("%s/index.json", slug),
+		})
+	}
+	sort.Slice(top.Connectors, func(i, j int) bool {
+		return top.Connectors[i].Index < top.Connectors[j].Index
+	})
+	top.TotalConnectors = len(top.Connectors)
+
+	if err := writeIndexDocument(schemas[schemaFileName("connectors")], ConnectorsJsonPath, top); err != nil {
+		return err
+	}
+
+	return WriteIndexSchemas()
+}
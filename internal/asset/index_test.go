@@ -0,0 +1,112 @@
+package asset
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteIndexJSON(t *testing.T) {
+	defer func(outputs, index, connectors string) {
+		OutputFolderPath, IndexJsonPath, ConnectorsJsonPath = outputs, index, connectors
+	}(OutputFolderPath, IndexJsonPath, ConnectorsJsonPath)
+	OutputFolderPath = t.TempDir()
+	IndexJsonPath = filepath.Join(OutputFolderPath, "index.json")
+	ConnectorsJsonPath = filepath.Join(OutputFolderPath, "connectors.json")
+
+	index := &Index{
+		TotalConnectors: 1,
+		Connectors:      []Connector{{Namespace: "hasura", Name: "foo", LatestVersion: "v2"}},
+		ConnectorVersions: map[string][]string{
+			"hasura/foo": {"v1", "v2"},
+			"acme/bar":   {"v1"},
+		},
+		Provenance: map[string]string{
+			"hasura/foo/v2": "hasura/foo/v2/provenance.intoto.json",
+		},
+		VersionStatus: map[string]VersionStatus{
+			"hasura/foo/v1": {Status: "yanked", Reason: "broken"},
+		},
+	}
+	if err := WriteIndexJSON(index); err != nil {
+		t.Fatal(err)
+	}
+
+	monolithic, err := ReadIndexJSON(OutputFolderPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(monolithic, index) {
+		t.Errorf("unexpected index.json\n got: %+v\nwant: %+v", monolithic, index)
+	}
+
+	var top ConnectorsIndex
+	readJSONFile(t, ConnectorsJsonPath, &top)
+	expectedTop := ConnectorsIndex{
+		TotalConnectors: 2,
+		Connectors: []IndexConnector{
+			{Connector: Connector{Namespace: "acme", Name: "bar"}, Index: "acme/bar/index.json"},
+			{Connector: Connector{Namespace: "hasura", Name: "foo", LatestVersion: "v2"}, Index: "hasura/foo/index.json"},
+		},
+	}
+	if !reflect.DeepEqual(top, expectedTop) {
+		t.Errorf("unexpected connectors.json\n got: %+v\nwant: %+v", top, expectedTop)
+	}
+
+	var foo ConnectorIndex
+	readJSONFile(t, filepath.Join(OutputFolderPath, "hasura", "foo", "index.json"), &foo)
+	expectedFoo := ConnectorIndex{
+		Connector:     Connector{Namespace: "hasura", Name: "foo", LatestVersion: "v2"},
+		Versions:      []string{"v1", "v2"},
+		Provenance:    map[string]string{"v2": "hasura/foo/v2/provenance.intoto.json"},
+		VersionStatus: map[string]VersionStatus{"v1": {Status: "yanked", Reason: "broken"}},
+	}
+	if !reflect.DeepEqual(foo, expectedFoo) {
+		t.Errorf("unexpected connector index.json\n got: %+v\nwant: %+v", foo, expectedFoo)
+	}
+
+	// an index without connectors is written with empty arrays and objects
+	if err := WriteIndexJSON(&Index{ConnectorVersions: map[string][]string{"acme/bar": nil}}); err != nil {
+		t.Fatal(err)
+	}
+	for name, path := range map[string]string{
+		"index":           IndexJsonPath,
+		"connectors":      ConnectorsJsonPath,
+		"connector-index": filepath.Join(OutputFolderPath, "acme", "bar", "index.json"),
+	} {
+		var doc any
+		readJSONFile(t, path, &doc)
+		if problems := readPublishedSchema(t, name).Validate(doc); len(problems) > 0 {
+			t.Errorf("%s does not match the published schema: %q", path, problems)
+		}
+	}
+}
+
+func TestWriteIndexDocumentInvalid(t *testing.T) {
+	schemas, err := IndexSchemas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "index.json")
+	err = writeIndexDocument(schemas[schemaFileName("index")], path, map[string]any{"total_connectors": "1"})
+	if err == nil || !strings.Contains(err.Error(), "$.total_connectors: expected integer") {
+		t.Fatalf("expected the schema problems, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written, got %v", err)
+	}
+}
+
+func readJSONFile(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
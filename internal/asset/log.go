@@ -0,0 +1,39 @@
+package asset
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+// NewLogger creates a logger writing to w at level, one of debug, info, warn
+// or error, in the text or json format.
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+}
+
+// versionLogger returns the logger of run with the attributes of a connector
+// version, which every line about the version carries.
+func versionLogger(run *Run, namespace, name, version string) *slog.Logger {
+	return run.logger().With("namespace", namespace, "name", name, "version", version)
+}
+
+func connectorVersionLogger(run *Run, cp ndchub.ConnectorPackaging) *slog.Logger {
+	return versionLogger(run, cp.Namespace, cp.Name, cp.Version)
+}
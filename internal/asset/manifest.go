@@ -0,0 +1,201 @@
+package asset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	SHA256SumsFileName   = "SHA256SUMS"
+	ManifestJSONFileName = "manifest.json"
+)
+
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// ManifestReport lists the differences between an outputs folder and its
+// manifest, as paths relative to the folder.
+type ManifestReport struct {
+	Missing  []string
+	Extra    []string
+	Modified []string
+}
+
+func (r *ManifestReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Modified) == 0
+}
+
+func (r *ManifestReport) String() string {
+	var sb strings.Builder
+	for _, section := range []struct {
+		title string
+		paths []string
+	}{
+		{"missing", r.Missing},
+		{"extra", r.Extra},
+		{"modified", r.Modified},
+	} {
+		for _, p := range section.paths {
+			fmt.Fprintf(&sb, "%s: %s\n", section.title, p)
+		}
+	}
+	return sb.String()
+}
+
+// isManifestFile reports whether a file of the outputs folder is left out of
+// the manifest: the manifests themselves, and the detached signatures, which
+// are written after the manifests so that they can be signed too.
+func isManifestFile(relPath string) bool {
+	return relPath == SHA256SumsFileName || relPath == ManifestJSONFileName || strings.HasSuffix(relPath, ".sig")
+}
+
+// buildManifest hashes every file of the outputs folder, except the manifests
+// themselves and the signatures.
+func buildManifest(outputsFolder string) (*Manifest, error) {
+	var manifest Manifest
+	err := filepath.WalkDir(outputsFolder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(outputsFolder, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isManifestFile(rel) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sha, err := getSHAIfFileExists(path)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Path: rel, SHA256: sha, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking the outputs folder: %w", err)
+	}
+
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	return &manifest, nil
+}
+
+// WriteOutputManifest writes a SHA256SUMS file covering every file of the
+// outputs folder and, with withJSON, the same listing as manifest.json.
+func WriteOutputManifest(outputsFolder string, withJSON bool) error {
+	manifest, err := buildManifest(outputsFolder)
+	if err != nil {
+		return err
+	}
+
+	var sums strings.Builder
+	for _, f := range manifest.Files {
+		fmt.Fprintf(&sums, "%s  %s\n", f.SHA256, f.Path)
+	}
+	sumsPath := filepath.Join(outputsFolder, SHA256SumsFileName)
+	err = os.WriteFile(sumsPath, []byte(sums.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %s", sumsPath, err)
+	}
+
+	if !withJSON {
+		return nil
+	}
+
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while marshalling manifest json")
+	}
+	manifestPath := filepath.Join(outputsFolder, ManifestJSONFileName)
+	err = os.WriteFile(manifestPath, manifestJson, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %s", manifestPath, err)
+	}
+	return nil
+}
+
+func readSHA256Sums(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseSHA256Sums(file, path)
+}
+
+// parseSHA256Sums reads a SHA256SUMS listing, name is used in errors.
+func parseSHA256Sums(r io.Reader, name string) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		sha, rel, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: malformed line", name, line)
+		}
+		sums[rel] = sha
+	}
+	return sums, scanner.Err()
+}
+
+// VerifyOutputManifest compares an outputs folder against its SHA256SUMS
+// file.
+func VerifyOutputManifest(outputsFolder string) (*ManifestReport, error) {
+	expected, err := readSHA256Sums(filepath.Join(outputsFolder, SHA256SumsFileName))
+	if err != nil {
+		return nil, err
+	}
+	actual, err := buildManifest(outputsFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	var report ManifestReport
+	seen := make(map[string]bool)
+	for _, f := range actual.Files {
+		seen[f.Path] = true
+		sha, ok := expected[f.Path]
+		switch {
+		case !ok:
+			report.Extra = append(report.Extra, f.Path)
+		case sha != f.SHA256:
+			report.Modified = append(report.Modified, f.Path)
+		}
+	}
+	for rel := range expected {
+		if !seen[rel] {
+			report.Missing = append(report.Missing, rel)
+		}
+	}
+	sort.Strings(report.Missing)
+	return &report, nil
+}
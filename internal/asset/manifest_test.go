@@ -0,0 +1,65 @@
+package asset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyOutputManifest(t *testing.T) {
+	outputs := t.TempDir()
+	files := map[string]string{
+		"index.json": `{"total_connectors": 1}`,
+		"hasura/foo/v0.1.0/connector-definition.tar.gz":                     "tarball",
+		"hasura/foo/v0.1.0/cli-plugins/linux-amd64/plugin.tar.gz":           "plugin",
+		"hasura/foo/v0.1.0/cli-plugins/darwin-arm64/plugin.tar.gz":          "plugin",
+		"hasura/bar/v1.0.0/connector-definition.tar.gz":                     "other tarball",
+		"hasura/bar/v1.0.0/cli-plugins/windows-amd64/hasura-bar.exe":        "binary",
+		"hasura/bar/v1.0.0/cli-plugins/windows-amd64/hasura-bar.exe.sha256": "checksum",
+	}
+	for rel, content := range files {
+		p := filepath.Join(outputs, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := WriteOutputManifest(outputs, true); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyOutputManifest(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected a freshly written manifest to match, got:\n%s", report)
+	}
+
+	if err := os.Remove(filepath.Join(outputs, "hasura/bar/v1.0.0/connector-definition.tar.gz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputs, "index.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outputs, "stale.tar.gz"), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = VerifyOutputManifest(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ManifestReport{
+		Missing:  []string{"hasura/bar/v1.0.0/connector-definition.tar.gz"},
+		Extra:    []string{"stale.tar.gz"},
+		Modified: []string{"index.json"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected %+v, got %+v", expected, report)
+	}
+}
@@ -0,0 +1,213 @@
+package asset
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+// Metrics is a ProgressReporter collecting the metrics of a generation run, to
+// be written in the Prometheus text format for the node_exporter textfile
+// collector or pushed to a Pushgateway.
+type Metrics struct {
+	mu sync.Mutex
+
+	started        time.Time
+	stageStarted   map[string]time.Time
+	stageDurations map[string]time.Duration
+	// versions counts the versions done by each stage, by result
+	versions map[string]map[string]int
+
+	downloadBytes    int64
+	artefacts        map[string]int
+	checksumFailures int
+
+	connectors        int
+	connectorVersions int
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		started:        time.Now(),
+		stageStarted:   make(map[string]time.Time),
+		stageDurations: make(map[string]time.Duration),
+		versions:       make(map[string]map[string]int),
+		artefacts:      make(map[string]int),
+	}
+}
+
+func (m *Metrics) StageStarted(stage string, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stageStarted[stage] = time.Now()
+	if m.versions[stage] == nil {
+		m.versions[stage] = map[string]int{"ok": 0, "failed": 0}
+	}
+}
+
+func (m *Metrics) VersionDone(stage string, cp ndchub.ConnectorPackaging, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+	m.versions[stage][result]++
+}
+
+func (m *Metrics) StageFinished(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stageDurations[stage] += time.Since(m.stageStarted[stage])
+}
+
+func (m *Metrics) BytesDownloaded(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.downloadBytes += n
+}
+
+func (m *Metrics) ArtefactResolved(source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.artefacts[source]++
+}
+
+func (m *Metrics) ChecksumFailed(uri string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checksumFailures++
+}
+
+// SetCounts records the number of connectors and connector versions read
+// from ndc-hub.
+func (m *Metrics) SetCounts(connectors, connectorVersions int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connectors = connectors
+	m.connectorVersions = connectorVersions
+}
+
+// Render returns the metrics in the Prometheus text exposition format, with
+// success telling whether the run succeeded.
+func (m *Metrics) Render(success bool) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf bytes.Buffer
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("ddn_assets_run_success", "gauge", "Whether the last generation run succeeded.")
+	fmt.Fprintf(&buf, "ddn_assets_run_success %d\n", boolToInt(success))
+	metric("ddn_assets_run_duration_seconds", "gauge", "Duration of the last generation run.")
+	fmt.Fprintf(&buf, "ddn_assets_run_duration_seconds %g\n", time.Since(m.started).Seconds())
+	metric("ddn_assets_last_run_timestamp_seconds", "gauge", "Unix time the last generation run finished at.")
+	fmt.Fprintf(&buf, "ddn_assets_last_run_timestamp_seconds %d\n", time.Now().Unix())
+
+	metric("ddn_assets_stage_duration_seconds", "gauge", "Duration of each stage of the last generation run.")
+	for _, stage := range sortedKeys(m.stageDurations) {
+		fmt.Fprintf(&buf, "ddn_assets_stage_duration_seconds{stage=%q} %g\n", stage, m.stageDurations[stage].Seconds())
+	}
+	metric("ddn_assets_stage_versions", "gauge", "Connector versions processed by each stage, by result.")
+	for _, stage := range sortedKeys(m.versions) {
+		for _, result := range sortedKeys(m.versions[stage]) {
+			fmt.Fprintf(&buf, "ddn_assets_stage_versions{stage=%q,result=%q} %d\n", stage, result, m.versions[stage][result])
+		}
+	}
+
+	metric("ddn_assets_download_bytes", "gauge", "Bytes downloaded over the network.")
+	fmt.Fprintf(&buf, "ddn_assets_download_bytes %d\n", m.downloadBytes)
+	metric("ddn_assets_artefacts", "gauge", "Artefacts resolved, by where they were resolved from.")
+	for _, source := range []string{ArtefactExisting, ArtefactCache, ArtefactMirror, ArtefactNetwork} {
+		fmt.Fprintf(&buf, "ddn_assets_artefacts{source=%q} %d\n", source, m.artefacts[source])
+	}
+	metric("ddn_assets_cache_hit_ratio", "gauge", "Share of the artefacts looked up in the download cache that were found there.")
+	lookups := m.artefacts[ArtefactCache] + m.artefacts[ArtefactMirror] + m.artefacts[ArtefactNetwork]
+	ratio := 0.0
+	if lookups > 0 {
+		ratio = float64(m.artefacts[ArtefactCache]) / float64(lookups)
+	}
+	fmt.Fprintf(&buf, "ddn_assets_cache_hit_ratio %g\n", ratio)
+	metric("ddn_assets_checksum_failures", "gauge", "Artefacts that did not match their checksum.")
+	fmt.Fprintf(&buf, "ddn_assets_checksum_failures %d\n", m.checksumFailures)
+
+	metric("ddn_assets_connectors", "gauge", "Connectors in ndc-hub.")
+	fmt.Fprintf(&buf, "ddn_assets_connectors %d\n", m.connectors)
+	metric("ddn_assets_connector_versions", "gauge", "Connector versions in ndc-hub.")
+	fmt.Fprintf(&buf, "ddn_assets_connector_versions %d\n", m.connectorVersions)
+
+	return buf.Bytes()
+}
+
+// WriteTextfile writes the metrics to a .prom file, atomically so that the
+// textfile collector never reads a partial file.
+func (m *Metrics) WriteTextfile(path string, success bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(m.Render(success)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// pushClient bounds a push, so that an unreachable gateway does not hang the
+// end of a run.
+var pushClient = &http.Client{Timeout: 30 * time.Second}
+
+// Push replaces the metrics of the ddn-assets job on the Pushgateway at
+// gatewayURL.
+func (m *Metrics) Push(gatewayURL string, success bool) error {
+	url := strings.TrimSuffix(gatewayURL, "/") + "/metrics/job/ddn-assets"
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(m.Render(success)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error pushing metrics to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("error pushing metrics to %s: status code %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
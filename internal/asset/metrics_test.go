@@ -0,0 +1,85 @@
+package asset
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	run := &Run{Progress: metrics}
+
+	connPkgs := []ndchub.ConnectorPackaging{
+		{Namespace: "hasura", Name: "foo", Version: "v1"},
+		{Namespace: "hasura", Name: "foo", Version: "v2"},
+	}
+	_ = forEachVersion(run, StageDownload, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		if cp.Version == "v2" {
+			run.progress().ArtefactResolved(ArtefactNetwork)
+			run.progress().ChecksumFailed("https://example.com/v2.tar.gz")
+			return errors.New("boom")
+		}
+		run.progress().ArtefactResolved(ArtefactCache)
+		_, _ = progressReader{strings.NewReader(strings.Repeat("x", 1024)), run.progress()}.Read(make([]byte, 2048))
+		return nil
+	})
+	metrics.SetCounts(1, 2)
+
+	rendered := string(metrics.Render(false))
+	for _, line := range []string{
+		"ddn_assets_run_success 0",
+		`ddn_assets_stage_versions{stage="download",result="failed"} 1`,
+		`ddn_assets_stage_versions{stage="download",result="ok"} 1`,
+		"ddn_assets_download_bytes 1024",
+		`ddn_assets_artefacts{source="cache"} 1`,
+		"ddn_assets_cache_hit_ratio 0.5",
+		"ddn_assets_checksum_failures 1",
+		"ddn_assets_connectors 1",
+		"ddn_assets_connector_versions 2",
+		"# TYPE ddn_assets_stage_duration_seconds gauge",
+	} {
+		if !strings.Contains(rendered, line+"\n") {
+			t.Errorf("expected %q in the metrics:\n%s", line, rendered)
+		}
+	}
+	if !strings.Contains(rendered, `ddn_assets_stage_duration_seconds{stage="download"} `) {
+		t.Errorf("expected the download stage duration in the metrics:\n%s", rendered)
+	}
+
+	path := filepath.Join(t.TempDir(), "ddn_assets.prom")
+	if err := metrics.WriteTextfile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "ddn_assets_run_success 1\n") {
+		t.Errorf("unexpected textfile:\n%s", data)
+	}
+
+	var pushed string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/metrics/job/ddn-assets" {
+			t.Errorf("unexpected push %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		pushed = string(body)
+	}))
+	defer server.Close()
+	if err := metrics.Push(server.URL+"/", true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(pushed, "ddn_assets_connectors 1\n") {
+		t.Errorf("unexpected pushed metrics:\n%s", pushed)
+	}
+}
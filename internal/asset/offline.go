@@ -0,0 +1,225 @@
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hasura/ddn-assets/internal/fetch"
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/signature"
+	"golang.org/x/sync/errgroup"
+)
+
+// Artefact is a remote file the generation depends on.
+type Artefact struct {
+	URI    string
+	SHA256 string
+	// Path is where the artefact is stored by the generation, an up to date
+	// copy there does not need to be fetched again.
+	Path string
+	// Source names the field of the connector version the uri comes from.
+	Source string
+	// Optional marks an artefact that may not exist upstream, like the
+	// signature of an unsigned tarball in a namespace that does not require
+	// signatures.
+	Optional bool
+
+	Namespace string
+	Name      string
+	Version   string
+}
+
+// ConnectorTarballArtefacts lists the connector tarballs, along with their
+// signatures in the namespaces the policy has keys for.
+func ConnectorTarballArtefacts(connPkgs []ndchub.ConnectorPackaging, policy *signature.Policy) []Artefact {
+	var artefacts []Artefact
+	for _, cp := range connPkgs {
+		artefacts = append(artefacts, Artefact{
+			URI:    cp.URI,
+			SHA256: cp.Checksum.Value,
+			Path:   connectorTarballDownloadPath(cp.Namespace, cp.Name, cp.Version),
+			Source: uriSource(cp, "connector-packaging.json uri"),
+
+			Namespace: cp.Namespace,
+			Name:      cp.Name,
+			Version:   cp.Version,
+		})
+
+		keys, required := policy.Keys(cp.Namespace)
+		if len(keys) == 0 {
+			continue
+		}
+		artefacts = append(artefacts, Artefact{
+			URI:      cp.SignatureURI(),
+			Path:     connectorSignatureDownloadPath(cp.Namespace, cp.Name, cp.Version),
+			Source:   uriSource(cp, "connector-packaging.json signature uri"),
+			Optional: !required,
+
+			Namespace: cp.Namespace,
+			Name:      cp.Name,
+			Version:   cp.Version,
+		})
+	}
+	return artefacts
+}
+
+// CLIPluginArtefacts lists the cli plugin platform files of the extracted
+// connector definitions.
+func CLIPluginArtefacts(connPkgs []ndchub.ConnectorPackaging, extractBinaries bool) ([]Artefact, error) {
+	var artefacts []Artefact
+	for _, cp := range connPkgs {
+		cliPlugin, _, err := readBinaryInlineCLIPlugin(connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version))
+		if err != nil {
+			return nil, err
+		}
+		if cliPlugin == nil {
+			continue
+		}
+
+		for idx, p := range cliPlugin.Platforms {
+			pluginPath, err := cliPluginDownloadPath(cp, p, extractBinaries)
+			if err != nil {
+				return nil, err
+			}
+			artefacts = append(artefacts, Artefact{
+				URI:    p.URI,
+				SHA256: p.SHA256,
+				Path:   pluginPath,
+				Source: uriSource(cp, fmt.Sprintf("connector-metadata.yaml cliPlugin.platforms[%d].uri", idx)),
+
+				Namespace: cp.Namespace,
+				Name:      cp.Name,
+				Version:   cp.Version,
+			})
+		}
+	}
+	return artefacts, nil
+}
+
+// MissingOfflineArtefacts returns the artefacts that can not be resolved
+// without network access.
+func MissingOfflineArtefacts(run *Run, artefacts []Artefact) []Artefact {
+	var missing []Artefact
+	for _, a := range artefacts {
+		// an artefact without a checksum is up to date wherever fetch stored it
+		if sha, _ := getSHAIfFileExists(a.Path); sha != "" && (sha == a.SHA256 || a.SHA256 == "") {
+			continue
+		}
+		if run.Cache != nil && run.Cache.Has(a.SHA256) {
+			continue
+		}
+		if mirrorPath, err := mirrorFilePath(run, a.URI); err == nil {
+			if _, err := os.Stat(mirrorPath); err == nil {
+				continue
+			}
+		}
+		if a.Optional {
+			continue
+		}
+		missing = append(missing, a)
+	}
+	return missing
+}
+
+// FetchArtefacts downloads the artefacts into the cache of run, skipping the ones
+// it already holds. The cache is content-addressed, so the artefacts without
+// a checksum are stored at their path instead, for an offline generate to
+// pick up. Optional artefacts that do not exist are skipped.
+func FetchArtefacts(run *Run, artefacts []Artefact) error {
+	if run.Cache == nil {
+		return errors.New("no download cache configured")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "ddn-assets-fetch")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, a := range artefacts {
+		if err := checkURI(run, a.Source, a.URI); err != nil {
+			return err
+		}
+	}
+
+	var fetches errgroup.Group
+	seen := make(map[string]bool)
+	for idx, a := range artefacts {
+		// artefacts without a checksum can only be told apart by their uri
+		key := a.SHA256
+		if key == "" {
+			key = a.URI
+		}
+		if seen[key] || run.Cache.Has(a.SHA256) {
+			continue
+		}
+		seen[key] = true
+		fetches.Go(func() error {
+			// downloadFile adds the artefact to the cache once downloaded
+			destPath := filepath.Join(tmpDir, fmt.Sprint(idx))
+			if a.SHA256 == "" && a.Path != "" {
+				destPath = a.Path
+				// a copy from an earlier fetch may be stale
+				_ = os.Remove(destPath)
+				if err := os.MkdirAll(filepath.Dir(destPath), 0777); err != nil {
+					return fmt.Errorf("error creating folder: %s %w", filepath.Dir(destPath), err)
+				}
+			}
+			err := downloadFile(run, nil, versionLogger(run, a.Namespace, a.Name, a.Version), a.URI, destPath, a.SHA256)
+			if a.Optional && fetch.IsNotFound(err) {
+				return nil
+			}
+			return err
+		})
+	}
+	return fetches.Wait()
+}
+
+func mirrorFilePath(run *Run, uri string) (string, error) {
+	if run.MirrorDir == "" {
+		return "", errors.New("no mirror directory configured")
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(run.MirrorDir, u.Host, filepath.FromSlash(strings.TrimPrefix(u.Path, "/"))), nil
+}
+
+// copyFromMirror copies the artefact at uri from the mirror of run to
+// destPath.
+func copyFromMirror(run *Run, uri, destPath, sha256checksum string) error {
+	mirrorPath, err := mirrorFilePath(run, uri)
+	if err != nil {
+		return fmt.Errorf("%s is not available offline: %w: %w", uri, err, fs.ErrNotExist)
+	}
+	if _, err := os.Stat(mirrorPath); err != nil {
+		return fmt.Errorf("%s is not available offline: %w", uri, err)
+	}
+
+	if sha256checksum != "" {
+		sha, err := getSHAIfFileExists(mirrorPath)
+		if err != nil {
+			return err
+		}
+		if sha != sha256checksum {
+			run.progress().ChecksumFailed(uri)
+			return fmt.Errorf("checksum mismatch for %s in the mirror: expected %s, got %s", mirrorPath, sha256checksum, sha)
+		}
+	}
+	return copyFile(mirrorPath, destPath)
+}
+
+// FormatArtefacts lists artefacts one per line for error messages.
+func FormatArtefacts(artefacts []Artefact) string {
+	var lines []string
+	for _, a := range artefacts {
+		lines = append(lines, fmt.Sprintf("%s (sha256: %s)", a.URI, a.SHA256))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,164 @@
+package asset
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+func TestMissingOfflineArtefacts(t *testing.T) {
+	mirror := t.TempDir()
+	mirrored := filepath.Join(mirror, "github.com", "hasura", "ndc-foo", "releases", "download", "v1.0.0", "connector-definition.tar.gz")
+	if err := os.MkdirAll(filepath.Dir(mirrored), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mirrored, []byte("tarball"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sha, err := getSHAIfFileExists(mirrored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := &Run{MirrorDir: mirror}
+
+	artefacts := []Artefact{
+		{URI: "https://github.com/hasura/ndc-foo/releases/download/v1.0.0/connector-definition.tar.gz", SHA256: sha},
+		{URI: "https://github.com/hasura/ndc-foo/releases/download/v2.0.0/connector-definition.tar.gz", SHA256: "missing"},
+	}
+	missing := MissingOfflineArtefacts(run, artefacts)
+	if len(missing) != 1 || missing[0].URI != artefacts[1].URI {
+		t.Fatalf("expected only the unmirrored artefact to be missing, got %+v", missing)
+	}
+
+	dest := filepath.Join(t.TempDir(), "connector-definition.tar.gz")
+	if err := copyFromMirror(run, artefacts[0].URI, dest, sha); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyFromMirror(run, artefacts[0].URI, dest, "other"); err == nil {
+		t.Error("expected a checksum mismatch error")
+	}
+	if err := copyFromMirror(run, artefacts[1].URI, dest, ""); err == nil {
+		t.Error("expected an error for an artefact missing from the mirror")
+	}
+}
+
+func TestFetchArtefacts(t *testing.T) {
+	requests := make(map[string]int)
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/missing.tar.gz.sig" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer server.Close()
+
+	cache, err := NewCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &Run{Cache: cache}
+
+	artefacts := []Artefact{
+		{URI: server.URL + "/a.tar.gz"},
+		{URI: server.URL + "/b.tar.gz"},
+		{URI: server.URL + "/a.tar.gz"},
+	}
+	if err := FetchArtefacts(run, artefacts); err != nil {
+		t.Fatal(err)
+	}
+	if requests["/a.tar.gz"] != 1 || requests["/b.tar.gz"] != 1 {
+		t.Errorf("expected every artefact without a checksum to be fetched once, got %v", requests)
+	}
+	for _, content := range []string{"/a.tar.gz", "/b.tar.gz"} {
+		if !cache.Has(fmt.Sprintf("%x", sha256.Sum256([]byte(content)))) {
+			t.Errorf("expected %s in the download cache", content)
+		}
+	}
+
+	// signatures carry no checksum, they are stored at their path
+	sigPath := filepath.Join(t.TempDir(), "signatures", "a.tar.gz.sig")
+	err = FetchArtefacts(run, []Artefact{
+		{URI: server.URL + "/a.tar.gz.sig", Path: sigPath},
+		{URI: server.URL + "/missing.tar.gz.sig", Path: filepath.Join(t.TempDir(), "missing.tar.gz.sig"), Optional: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(sigPath); err != nil || string(data) != "/a.tar.gz.sig" {
+		t.Errorf("expected the signature at %s, got %q, %v", sigPath, data, err)
+	}
+	err = FetchArtefacts(run, []Artefact{{URI: server.URL + "/missing.tar.gz.sig", Path: filepath.Join(t.TempDir(), "missing.tar.gz.sig")}})
+	if err == nil {
+		t.Error("expected an error for a missing artefact that is not optional")
+	}
+}
+
+func TestSignatureArtefacts(t *testing.T) {
+	useTestAssetFolders(t)
+	policy := writeTestSignaturePolicy(t, "namespaces:\n  hasura:\n    keys: [key.pub]\n  strict:\n    required: true\n    keys: [key.pub]\n")
+
+	connPkgs := []ndchub.ConnectorPackaging{
+		{Namespace: "hasura", Name: "foo", Version: "v1", URI: "https://example.com/foo.tar.gz", Checksum: ndchub.Checksum{Value: "foo-sha"}},
+		{Namespace: "strict", Name: "bar", Version: "v1", URI: "https://example.com/bar.tar.gz", Checksum: ndchub.Checksum{Value: "bar-sha"}},
+		{Namespace: "other", Name: "baz", Version: "v1", URI: "https://example.com/baz.tar.gz", Checksum: ndchub.Checksum{Value: "baz-sha"}},
+	}
+	artefacts := ConnectorTarballArtefacts(connPkgs, policy)
+	var uris []string
+	for _, a := range artefacts {
+		uris = append(uris, a.URI)
+	}
+	expected := []string{
+		"https://example.com/foo.tar.gz",
+		"https://example.com/foo.tar.gz.sig",
+		"https://example.com/bar.tar.gz",
+		"https://example.com/bar.tar.gz.sig",
+		"https://example.com/baz.tar.gz",
+	}
+	if !reflect.DeepEqual(uris, expected) {
+		t.Fatalf("expected artefacts %v, got %v", expected, uris)
+	}
+
+	// the signatures required by their namespace are missing, until stored
+	// next to the downloads
+	var missing []string
+	for _, a := range MissingOfflineArtefacts(&Run{}, artefacts) {
+		missing = append(missing, a.URI)
+	}
+	expected = []string{
+		"https://example.com/foo.tar.gz",
+		"https://example.com/bar.tar.gz",
+		"https://example.com/bar.tar.gz.sig",
+		"https://example.com/baz.tar.gz",
+	}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("expected missing artefacts %v, got %v", expected, missing)
+	}
+
+	sigPath := connectorSignatureDownloadPath("strict", "bar", "v1")
+	if err := os.MkdirAll(filepath.Dir(sigPath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sigPath, []byte("signature"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range MissingOfflineArtefacts(&Run{}, artefacts) {
+		if a.URI == "https://example.com/bar.tar.gz.sig" {
+			t.Errorf("expected the stored signature not to be missing")
+		}
+	}
+}
@@ -7,32 +7,36 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/hasura/ddn-assets/internal/ndchub"
-	"golang.org/x/sync/errgroup"
+	"github.com/hasura/ddn-assets/internal/tracing"
 )
 
-func OutputConnectorTarballs(connPkgs []ndchub.ConnectorPackaging) error {
-	var targz errgroup.Group
-	for _, cp := range connPkgs {
-		targz.Go(func() error {
-			destFolder := outputConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version)
-			err := os.MkdirAll(destFolder, 0777)
-			if err != nil {
-				return fmt.Errorf("error creating folder: %s %w", destFolder, err)
-			}
-
-			return tarGzFolder(
-				extractedConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version),
-				connectorTarballOutputPath(cp.Namespace, cp.Name, cp.Version),
-			)
-		})
-	}
-	return targz.Wait()
+func OutputConnectorTarballs(run *Run, connPkgs []ndchub.ConnectorPackaging) error {
+	return forEachVersion(run, StageOutput, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		destFolder := outputConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version)
+		err := os.MkdirAll(destFolder, 0777)
+		if err != nil {
+			return fmt.Errorf("error creating folder: %s %w", destFolder, err)
+		}
+
+		tarballPath := connectorTarballOutputPath(cp.Namespace, cp.Name, cp.Version)
+		if err := run.KeepGoing.Wrote(cp, tarballPath); err != nil {
+			return err
+		}
+		return tarGzFolder(extractedConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version), tarballPath)
+	})
 }
 
+// reproducibleModTime is the modification time of every entry of the output
+// tarballs.
+var reproducibleModTime = time.Unix(0, 0)
+
 // tarGzFolder takes a source directory and creates a .tar.gz file at the destination path,
-// with files and folders at the root of the archive.
+// with files and folders at the root of the archive. The archive is reproducible: entries
+// are written in lexical order, without timestamps or owners, so that regenerating the same
+// connector definition gives the same checksum.
 func tarGzFolder(sourceDir, destFile string) error {
 	outFile, err := os.Create(destFile)
 	if err != nil {
@@ -45,6 +49,7 @@ func tarGzFolder(sourceDir, destFile string) error {
 	tarWriter := tar.NewWriter(gzWriter)
 	defer tarWriter.Close()
 
+	// filepath.Walk visits the entries of each folder in lexical order
 	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -54,6 +59,11 @@ func tarGzFolder(sourceDir, destFile string) error {
 		if err != nil {
 			return err
 		}
+		header.ModTime = reproducibleModTime
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
 
 		// Adjust the header name to ensure relative paths within the archive
 		header.Name, err = filepath.Rel(filepath.Dir(sourceDir+"/"), path)
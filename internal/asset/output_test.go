@@ -0,0 +1,100 @@
+package asset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+// useTestAssetFolders points the asset folders at a temporary folder for the
+// duration of a test.
+func useTestAssetFolders(t *testing.T) {
+	t.Helper()
+	assets := t.TempDir()
+	downloads, extracts, outputs := DownloadsFolderPath, ExtractsFolderPath, OutputFolderPath
+	t.Cleanup(func() {
+		DownloadsFolderPath, ExtractsFolderPath, OutputFolderPath = downloads, extracts, outputs
+	})
+	DownloadsFolderPath = filepath.Join(assets, "downloads")
+	ExtractsFolderPath = filepath.Join(assets, "extracts")
+	OutputFolderPath = filepath.Join(assets, "outputs")
+	if err := CreateAssetFolders(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutputsReproducible(t *testing.T) {
+	useTestAssetFolders(t)
+
+	cp := ndchub.ConnectorPackaging{Namespace: "hasura", Name: "foo", Version: "v1"}
+	connPkgs := []ndchub.ConnectorPackaging{cp}
+	tarball := writeTestTarGz(t, map[string]string{
+		".hasura-connector/connector-metadata.yaml": "packagingDefinition:\n  type: PrebuiltDockerImage\n",
+		"docker/Dockerfile":                         "FROM scratch\n",
+	})
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloadPath := connectorTarballDownloadPath(cp.Namespace, cp.Name, cp.Version)
+	if err := os.MkdirAll(filepath.Dir(downloadPath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(downloadPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(mtime time.Time) string {
+		t.Helper()
+		if err := os.RemoveAll(ExtractsFolderPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := ExtractConnectorTarballs(&Run{}, connPkgs); err != nil {
+			t.Fatal(err)
+		}
+		// a later run extracts the definition at another time
+		err := filepath.Walk(ExtractsFolderPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Chtimes(path, mtime, mtime)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := OutputConnectorTarballs(&Run{}, connPkgs); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteOutputManifest(OutputFolderPath, false); err != nil {
+			t.Fatal(err)
+		}
+		sums, err := os.ReadFile(filepath.Join(OutputFolderPath, SHA256SumsFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(sums)
+	}
+
+	first := generate(time.Now().Add(-time.Hour))
+	second := generate(time.Now())
+	if first != second {
+		t.Errorf("expected the same SHA256SUMS from both generations\nfirst:\n%s\nsecond:\n%s", first, second)
+	}
+}
+
+// writeTestConnectorMetadata writes the connector-metadata.yaml of an
+// extracted connector version.
+func writeTestConnectorMetadata(t *testing.T, cp ndchub.ConnectorPackaging, metadata string) {
+	t.Helper()
+	path := connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
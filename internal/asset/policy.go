@@ -0,0 +1,154 @@
+package asset
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"gopkg.in/yaml.v3"
+)
+
+// DownloadPolicy is read from a YAML file like:
+//
+//	allowedSchemes: [https, oci]
+//	allowedHosts:
+//	  - github.com
+//	  - "*.githubusercontent.com"
+//	httpsOnly: true
+//	denyPrivateNetworks: true
+type DownloadPolicy struct {
+	// AllowedSchemes lists the uri schemes that can be downloaded from, all
+	// schemes with a fetcher are allowed when empty.
+	AllowedSchemes []string `yaml:"allowedSchemes"`
+	// AllowedHosts lists the hosts, or buckets for s3 uris, that can be
+	// downloaded from. A leading "*." matches any subdomain. All hosts are
+	// allowed when empty. The hosts requests are redirected to, registry
+	// token endpoints and s3 endpoints have to be listed too.
+	AllowedHosts []string `yaml:"allowedHosts"`
+	// HTTPSOnly refuses plain http uris.
+	HTTPSOnly bool `yaml:"httpsOnly"`
+	// DenyPrivateNetworks refuses hosts that are, or resolve to, loopback,
+	// private or link-local addresses. Addresses are checked as they are
+	// connected to.
+	DenyPrivateNetworks bool `yaml:"denyPrivateNetworks"`
+}
+
+func LoadDownloadPolicy(path string) (*DownloadPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy DownloadPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("error parsing download policy %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+// Check returns an error describing why uri is not allowed by the policy.
+// Host names are not resolved here: the fetchers check the addresses they
+// connect to, see CheckIP.
+func (p *DownloadPolicy) Check(uri string) error {
+	if p == nil {
+		return nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid uri %q: %w", uri, err)
+	}
+
+	if len(p.AllowedSchemes) > 0 && !containsString(p.AllowedSchemes, u.Scheme) {
+		return fmt.Errorf("scheme %q of %s is not allowed", u.Scheme, uri)
+	}
+	if err := p.checkHTTPSAndHost(u); err != nil {
+		return err
+	}
+
+	if p.DenyPrivateNetworks && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "oci") {
+		if ip := net.ParseIP(u.Hostname()); ip != nil {
+			if err := p.CheckIP(ip); err != nil {
+				return fmt.Errorf("%s: %w", uri, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckURL applies the policy to a url the fetchers request, which is the uri
+// of an artefact or one they are redirected to or reach the artefact
+// through, like a registry token endpoint or an s3 endpoint.
+func (p *DownloadPolicy) CheckURL(u *url.URL) error {
+	if u.Scheme == "http" && len(p.AllowedSchemes) > 0 && !containsString(p.AllowedSchemes, "http") {
+		return fmt.Errorf("scheme %q of %s is not allowed", u.Scheme, u)
+	}
+	return p.checkHTTPSAndHost(u)
+}
+
+// CheckIP refuses loopback, private and link-local addresses when private
+// networks are denied. The fetchers call it for every connection.
+func (p *DownloadPolicy) CheckIP(ip net.IP) error {
+	if !p.DenyPrivateNetworks {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%s is in a private network", ip)
+	}
+	return nil
+}
+
+func (p *DownloadPolicy) checkHTTPSAndHost(u *url.URL) error {
+	if p.HTTPSOnly && u.Scheme == "http" {
+		return fmt.Errorf("%s is not https", u)
+	}
+
+	host := u.Hostname()
+	if len(p.AllowedHosts) > 0 && !p.hostAllowed(host) {
+		return fmt.Errorf("host %q of %s is not allowed", host, u)
+	}
+	return nil
+}
+
+func (p *DownloadPolicy) hostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// uriSource names the field of a connector version a uri comes from.
+func uriSource(cp ndchub.ConnectorPackaging, field string) string {
+	return fmt.Sprintf("%s of %s/%s %s", field, cp.Namespace, cp.Name, cp.Version)
+}
+
+// checkURI applies the download policy of run to a uri, naming where the uri
+// comes from on a violation.
+func checkURI(run *Run, source, uri string) error {
+	if err := run.DownloadPolicy.Check(uri); err != nil {
+		return fmt.Errorf("download policy violation in %s: %w", source, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package asset
+
+import (
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestDownloadPolicy(t *testing.T) {
+	policy := &DownloadPolicy{
+		AllowedSchemes:      []string{"https", "http", "oci"},
+		AllowedHosts:        []string{"github.com", "*.githubusercontent.com", "*.github.com", "10.0.0.1", "127.0.0.1"},
+		DenyPrivateNetworks: true,
+	}
+
+	tt := []struct {
+		Name      string
+		Policy    *DownloadPolicy
+		URI       string
+		ExpectErr bool
+	}{
+		{Name: "No policy", URI: "http://192.168.1.1/tarball.tar.gz"},
+		{Name: "Allowed host", Policy: policy, URI: "https://github.com/hasura/ndc-foo/releases/download/v1.0.0/connector-definition.tar.gz"},
+		{Name: "Allowed subdomain", Policy: policy, URI: "https://objects.githubusercontent.com/tarball.tar.gz"},
+		{Name: "Unknown host", Policy: policy, URI: "https://example.com/tarball.tar.gz", ExpectErr: true},
+		{Name: "Scheme not allowed", Policy: policy, URI: "file:///tmp/tarball.tar.gz", ExpectErr: true},
+		{Name: "Private address", Policy: policy, URI: "https://10.0.0.1/tarball.tar.gz", ExpectErr: true},
+		{Name: "Loopback address", Policy: policy, URI: "oci://127.0.0.1:5000/hasura/ndc-foo:v1.0.0", ExpectErr: true},
+		{Name: "Plain http", Policy: &DownloadPolicy{HTTPSOnly: true}, URI: "http://github.com/tarball.tar.gz", ExpectErr: true},
+		{Name: "Https", Policy: &DownloadPolicy{HTTPSOnly: true}, URI: "https://github.com/tarball.tar.gz"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Policy.Check(tc.URI)
+			if tc.ExpectErr && err == nil {
+				t.Errorf("expected %s to be refused", tc.URI)
+			}
+			if !tc.ExpectErr && err != nil {
+				t.Errorf("expected %s to be allowed, got %v", tc.URI, err)
+			}
+		})
+	}
+
+	// host names are checked at connect time, by the address they resolve to
+	if err := policy.CheckIP(net.ParseIP("172.16.0.10")); err == nil {
+		t.Error("expected a private address to be refused")
+	}
+	if err := policy.CheckIP(net.ParseIP("140.82.112.3")); err != nil {
+		t.Errorf("expected a public address to be allowed, got %v", err)
+	}
+	redirect, _ := url.Parse("https://objects.example.com/tarball.tar.gz")
+	if err := policy.CheckURL(redirect); err == nil {
+		t.Error("expected a redirect to a host that is not allowed to be refused")
+	}
+}
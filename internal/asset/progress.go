@@ -0,0 +1,309 @@
+package asset
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+// ProgressReporter is told about the progress of the stages. Its methods are
+// called concurrently.
+type ProgressReporter interface {
+	// StageStarted is called before a stage processes its total connector
+	// versions.
+	StageStarted(stage string, total int)
+	// VersionDone is called once a stage is done with a connector version,
+	// err is nil when it succeeded.
+	VersionDone(stage string, cp ndchub.ConnectorPackaging, err error)
+	// StageFinished is called once every version of a stage is done.
+	StageFinished(stage string)
+	// BytesDownloaded is called as artefacts are downloaded.
+	BytesDownloaded(n int64)
+	// ArtefactResolved is called once an artefact is available, with the
+	// Artefact* source it was resolved from.
+	ArtefactResolved(source string)
+	// ChecksumFailed is called when a downloaded or mirrored artefact does
+	// not match its checksum.
+	ChecksumFailed(uri string)
+}
+
+type nopProgress struct{}
+
+func (nopProgress) StageStarted(string, int)                             {}
+func (nopProgress) VersionDone(string, ndchub.ConnectorPackaging, error) {}
+func (nopProgress) StageFinished(string)                                 {}
+func (nopProgress) BytesDownloaded(int64)                                {}
+func (nopProgress) ArtefactResolved(string)                              {}
+func (nopProgress) ChecksumFailed(string)                                {}
+
+// MultiProgress reports to every one of reporters.
+func MultiProgress(reporters ...ProgressReporter) ProgressReporter {
+	return multiProgress(reporters)
+}
+
+type multiProgress []ProgressReporter
+
+func (m multiProgress) StageStarted(stage string, total int) {
+	for _, r := range m {
+		r.StageStarted(stage, total)
+	}
+}
+
+func (m multiProgress) VersionDone(stage string, cp ndchub.ConnectorPackaging, err error) {
+	for _, r := range m {
+		r.VersionDone(stage, cp, err)
+	}
+}
+
+func (m multiProgress) StageFinished(stage string) {
+	for _, r := range m {
+		r.StageFinished(stage)
+	}
+}
+
+func (m multiProgress) BytesDownloaded(n int64) {
+	for _, r := range m {
+		r.BytesDownloaded(n)
+	}
+}
+
+func (m multiProgress) ArtefactResolved(source string) {
+	for _, r := range m {
+		r.ArtefactResolved(source)
+	}
+}
+
+func (m multiProgress) ChecksumFailed(uri string) {
+	for _, r := range m {
+		r.ChecksumFailed(uri)
+	}
+}
+
+// progressReader reports the bytes read through it as downloaded.
+type progressReader struct {
+	io.Reader
+	progress ProgressReporter
+}
+
+func (r progressReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.progress.BytesDownloaded(int64(n))
+	}
+	return n, err
+}
+
+type stageProgress struct {
+	name   string
+	total  int
+	done   int
+	failed int
+}
+
+// ProgressDisplay is a ProgressReporter rendering the state of the current
+// stage, either as a progress line redrawn in place on a terminal, or as
+// periodic log lines.
+type ProgressDisplay struct {
+	w           io.Writer
+	log         *slog.Logger
+	interactive bool
+	interval    time.Duration
+
+	bytes atomic.Int64
+	// drawing is set while the line of a stage is redrawn in place
+	drawing atomic.Bool
+
+	mu sync.Mutex
+	// the throughput is that of the bytes downloaded since the current or
+	// last stage started
+	since      time.Time
+	bytesSince int64
+	stage      *stageProgress
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewProgressDisplay creates a display redrawing its line on w when
+// interactive, and logging through log every interval otherwise.
+func NewProgressDisplay(w io.Writer, log *slog.Logger, interactive bool, interval time.Duration) *ProgressDisplay {
+	return &ProgressDisplay{
+		w:           w,
+		log:         log,
+		interactive: interactive,
+		interval:    interval,
+		since:       time.Now(),
+	}
+}
+
+func (d *ProgressDisplay) StageStarted(stage string, total int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stage = &stageProgress{name: stage, total: total}
+	d.since, d.bytesSince = time.Now(), d.bytes.Load()
+	d.stop = make(chan struct{})
+	d.drawing.Store(d.interactive)
+
+	d.wg.Add(1)
+	go d.run(d.stop)
+}
+
+func (d *ProgressDisplay) VersionDone(stage string, cp ndchub.ConnectorPackaging, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stage == nil || d.stage.name != stage {
+		return
+	}
+	d.stage.done++
+	if err != nil {
+		d.stage.failed++
+	}
+}
+
+func (d *ProgressDisplay) StageFinished(stage string) {
+	d.mu.Lock()
+	stop := d.stop
+	d.stop = nil
+	d.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	d.wg.Wait()
+
+	// the final state of every stage is always shown
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.render(true)
+	d.stage = nil
+	d.drawing.Store(false)
+}
+
+func (d *ProgressDisplay) BytesDownloaded(n int64) {
+	d.bytes.Add(n)
+}
+
+func (d *ProgressDisplay) ArtefactResolved(string) {}
+
+func (d *ProgressDisplay) ChecksumFailed(string) {}
+
+func (d *ProgressDisplay) run(stop chan struct{}) {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			d.render(false)
+			d.mu.Unlock()
+		}
+	}
+}
+
+// Status summarises the current stage, the bytes downloaded and the
+// download throughput since the current or last stage started.
+func (d *ProgressDisplay) Status() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status()
+}
+
+func (d *ProgressDisplay) status() string {
+	bytes := d.bytes.Load()
+	elapsed := time.Since(d.since).Seconds()
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(bytes-d.bytesSince) / elapsed
+	}
+
+	var sb strings.Builder
+	if d.stage != nil {
+		fmt.Fprintf(&sb, "%s %d/%d versions", d.stage.name, d.stage.done, d.stage.total)
+		if d.stage.failed > 0 {
+			fmt.Fprintf(&sb, " (%d failed)", d.stage.failed)
+		}
+		sb.WriteString(", ")
+	}
+	fmt.Fprintf(&sb, "%s downloaded at %s/s", formatBytes(bytes), formatBytes(int64(throughput)))
+	return sb.String()
+}
+
+func (d *ProgressDisplay) render(final bool) {
+	if d.stage == nil {
+		return
+	}
+	if !d.interactive {
+		d.log.Info("progress", "stage", d.stage.name, "done", d.stage.done, "total", d.stage.total,
+			"failed", d.stage.failed, "bytes", d.bytes.Load(), "status", d.status())
+		return
+	}
+
+	// redraw the line in place, clearing what is left of a longer line
+	fmt.Fprintf(d.w, "\r\033[K%s", d.status())
+	if final {
+		fmt.Fprintln(d.w)
+	}
+}
+
+// Logger returns log with its level raised to warn while an interactive
+// display redraws its line, so that the log lines do not interleave with it.
+// The line is cleared before a warning or error is logged, and redrawn after.
+func (d *ProgressDisplay) Logger(log *slog.Logger) *slog.Logger {
+	return slog.New(quietHandler{log.Handler(), d})
+}
+
+type quietHandler struct {
+	slog.Handler
+	d *ProgressDisplay
+}
+
+func (h quietHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < slog.LevelWarn && h.d.drawing.Load() {
+		return false
+	}
+	return h.Handler.Enabled(ctx, level)
+}
+
+func (h quietHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.d.drawing.Load() {
+		return h.Handler.Handle(ctx, r)
+	}
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	fmt.Fprint(h.d.w, "\r\033[K")
+	err := h.Handler.Handle(ctx, r)
+	if h.d.stage != nil && h.d.drawing.Load() {
+		fmt.Fprint(h.d.w, h.d.status())
+	}
+	return err
+}
+
+func (h quietHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return quietHandler{h.Handler.WithAttrs(attrs), h.d}
+}
+
+func (h quietHandler) WithGroup(name string) slog.Handler {
+	return quietHandler{h.Handler.WithGroup(name), h.d}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,85 @@
+package asset
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
+)
+
+func TestProgressDisplay(t *testing.T) {
+	var out bytes.Buffer
+	display := NewProgressDisplay(&out, slog.Default(), true, time.Hour)
+	run := &Run{Progress: display}
+
+	connPkgs := []ndchub.ConnectorPackaging{
+		{Namespace: "hasura", Name: "foo", Version: "v1"},
+		{Namespace: "hasura", Name: "foo", Version: "v2"},
+	}
+	err := forEachVersion(run, StageDownload, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		_, _ = progressReader{strings.NewReader(strings.Repeat("x", 1024)), display}.Read(make([]byte, 2048))
+		if cp.Version == "v2" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected the error of the failed version")
+	}
+
+	expected := "\r\033[Kdownload 2/2 versions (1 failed), 2.0 KiB downloaded at "
+	if !strings.HasPrefix(out.String(), expected) || !strings.HasSuffix(out.String(), "/s\n") {
+		t.Errorf("unexpected final progress line %q", out.String())
+	}
+	if status := display.Status(); !strings.HasPrefix(status, "2.0 KiB downloaded at ") {
+		t.Errorf("unexpected status after the stage %q", status)
+	}
+}
+
+func TestProgressDisplayLogger(t *testing.T) {
+	var out, logs bytes.Buffer
+	display := NewProgressDisplay(&out, slog.Default(), true, time.Hour)
+	log := display.Logger(slog.New(slog.NewTextHandler(&logs, nil))).With("namespace", "hasura")
+
+	display.StageStarted(StageDownload, 1)
+	log.Info("starting download")
+	log.Warn("no signature found")
+	display.StageFinished(StageDownload)
+	log.Info("file ready")
+
+	if strings.Contains(logs.String(), "starting download") {
+		t.Errorf("expected the info lines to be dropped while the stage is drawn:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "no signature found") || !strings.Contains(logs.String(), "file ready") {
+		t.Errorf("expected the warnings and the lines between stages to be logged:\n%s", logs.String())
+	}
+}
+
+func TestProgressDisplayLoggerRedraw(t *testing.T) {
+	var out bytes.Buffer
+	display := NewProgressDisplay(&out, slog.Default(), true, time.Hour)
+	log := display.Logger(slog.New(slog.NewTextHandler(&out, nil)))
+
+	display.StageStarted(StageDownload, 1)
+	display.mu.Lock()
+	display.render(false)
+	display.mu.Unlock()
+	log.Warn("no signature found")
+	display.StageFinished(StageDownload)
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected the warning and the final progress line, got %q", out.String())
+	}
+	if !strings.Contains(lines[0], "\r\033[Ktime=") {
+		t.Errorf("expected the progress line to be cleared before the warning, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "download 0/1 versions") {
+		t.Errorf("expected the progress line to be redrawn after the warning, got %q", lines[1])
+	}
+}
@@ -0,0 +1,155 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
+	"github.com/hasura/ddn-assets/internal/version"
+)
+
+// Provenance documents follow the in-toto statement layout with a SLSA
+// provenance predicate, see https://slsa.dev/spec/v1.0/provenance
+
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	generateBuildType   = "https://github.com/hasura/ddn-assets/generate@v1"
+	ddnAssetsBuilderID  = "https://github.com/hasura/ddn-assets"
+)
+
+// Transforms that can be applied to a connector definition on its way from
+// the downloaded tarball to the output tarball.
+const (
+	TransformRepackage             = "repackage"
+	TransformCLIPluginURIRewrite   = "cli-plugin-uri-rewrite"
+	TransformCLIPluginBinaryUnpack = "cli-plugin-binary-unpack"
+)
+
+type ProvenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     ProvenancePredicate  `json:"predicate"`
+}
+
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+type ProvenancePredicate struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies"`
+}
+
+type ExternalParameters struct {
+	Namespace  string   `json:"namespace"`
+	Name       string   `json:"name"`
+	Version    string   `json:"version"`
+	Transforms []string `json:"transforms"`
+}
+
+type RunDetails struct {
+	Builder Builder `json:"builder"`
+}
+
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+// ProvenanceIndexPath returns the path of the provenance document of a
+// connector version, relative to the outputs folder.
+func ProvenanceIndexPath(namespace, name, version string) string {
+	return path.Join(namespace, name, version, provenanceFileName)
+}
+
+// WriteProvenance writes a provenance document next to every output connector
+// tarball, recording where the definition came from and how it was changed.
+func WriteProvenance(run *Run, connPkgs []ndchub.ConnectorPackaging, extractBinaries bool) error {
+	return forEachVersion(run, StageProvenance, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		tarballPath := connectorTarballOutputPath(cp.Namespace, cp.Name, cp.Version)
+		sha, err := getSHAIfFileExists(tarballPath)
+		if err != nil {
+			return fmt.Errorf("error reading output tarball for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+		}
+
+		transforms := []string{TransformRepackage}
+		cliPlugin, _, err := readBinaryInlineCLIPlugin(connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version))
+		if err != nil {
+			return err
+		}
+		if cliPlugin != nil {
+			transforms = append(transforms, TransformCLIPluginURIRewrite)
+			if extractBinaries {
+				transforms = append(transforms, TransformCLIPluginBinaryUnpack)
+			}
+		}
+
+		checksumType := cp.Checksum.Type
+		if checksumType == "" {
+			checksumType = "sha256"
+		}
+		dependencies := []ResourceDescriptor{
+			{
+				Name:   connectorDefinitionTarballName,
+				URI:    cp.URI,
+				Digest: map[string]string{checksumType: cp.Checksum.Value},
+			},
+		}
+		if cp.Source.Hash != "" {
+			dependencies = append(dependencies, ResourceDescriptor{
+				Name:   "source",
+				Digest: map[string]string{"gitCommit": cp.Source.Hash},
+			})
+		}
+
+		statement := ProvenanceStatement{
+			Type: inTotoStatementType,
+			Subject: []ResourceDescriptor{
+				{Name: connectorDefinitionTarballName, Digest: map[string]string{"sha256": sha}},
+			},
+			PredicateType: slsaProvenanceType,
+			Predicate: ProvenancePredicate{
+				BuildDefinition: BuildDefinition{
+					BuildType: generateBuildType,
+					ExternalParameters: ExternalParameters{
+						Namespace:  cp.Namespace,
+						Name:       cp.Name,
+						Version:    cp.Version,
+						Transforms: transforms,
+					},
+					ResolvedDependencies: dependencies,
+				},
+				RunDetails: RunDetails{
+					Builder: Builder{
+						ID:      ddnAssetsBuilderID,
+						Version: map[string]string{"ddn-assets": version.Get()},
+					},
+				},
+			},
+		}
+
+		provenanceJson, err := json.MarshalIndent(statement, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error while marshalling provenance json")
+		}
+		provenancePath := filepath.Join(outputConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version), provenanceFileName)
+		if err := run.KeepGoing.Wrote(cp, provenancePath); err != nil {
+			return err
+		}
+		return os.WriteFile(provenancePath, provenanceJson, 0644)
+	})
+}
@@ -0,0 +1,97 @@
+package asset
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+func TestWriteProvenance(t *testing.T) {
+	useTestAssetFolders(t)
+
+	foo := ndchub.ConnectorPackaging{
+		Namespace: "hasura",
+		Name:      "foo",
+		Version:   "v1",
+		URI:       "https://example.com/foo.tar.gz",
+		Checksum:  ndchub.Checksum{Type: "sha256", Value: "foo-sha"},
+		Source:    ndchub.Source{Hash: "0123abcd"},
+	}
+	// without a checksum type nor a source hash
+	bar := ndchub.ConnectorPackaging{
+		Namespace: "hasura",
+		Name:      "bar",
+		Version:   "v1",
+		URI:       "https://example.com/bar.tar.gz",
+		Checksum:  ndchub.Checksum{Value: "bar-sha"},
+	}
+	writeTestConnectorMetadata(t, foo, fmt.Sprintf(testBinaryInlineMetadata, strings.Repeat("ab", 32)))
+	writeTestConnectorMetadata(t, bar, "packagingDefinition:\n  type: PrebuiltDockerImage\n  dockerImage: ghcr.io/hasura/bar:v1\n")
+	for _, cp := range []ndchub.ConnectorPackaging{foo, bar} {
+		if err := os.MkdirAll(outputConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(connectorTarballOutputPath(cp.Namespace, cp.Name, cp.Version), []byte(cp.Name+" output"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := WriteProvenance(&Run{}, []ndchub.ConnectorPackaging{foo, bar}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		cp           ndchub.ConnectorPackaging
+		dependencies []ResourceDescriptor
+		transforms   []string
+	}{
+		{
+			cp: foo,
+			dependencies: []ResourceDescriptor{
+				{Name: connectorDefinitionTarballName, URI: foo.URI, Digest: map[string]string{"sha256": "foo-sha"}},
+				{Name: "source", Digest: map[string]string{"gitCommit": "0123abcd"}},
+			},
+			transforms: []string{TransformRepackage, TransformCLIPluginURIRewrite, TransformCLIPluginBinaryUnpack},
+		},
+		{
+			cp: bar,
+			dependencies: []ResourceDescriptor{
+				{Name: connectorDefinitionTarballName, URI: bar.URI, Digest: map[string]string{"sha256": "bar-sha"}},
+			},
+			transforms: []string{TransformRepackage},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.cp.Name, func(t *testing.T) {
+			var statement ProvenanceStatement
+			readJSONFile(t, filepath.Join(OutputFolderPath, ProvenanceIndexPath(tc.cp.Namespace, tc.cp.Name, tc.cp.Version)), &statement)
+
+			if statement.Type != inTotoStatementType || statement.PredicateType != slsaProvenanceType {
+				t.Errorf("unexpected statement type %s with predicate %s", statement.Type, statement.PredicateType)
+			}
+			subject := []ResourceDescriptor{{
+				Name:   connectorDefinitionTarballName,
+				Digest: map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256([]byte(tc.cp.Name+" output")))},
+			}}
+			if !reflect.DeepEqual(statement.Subject, subject) {
+				t.Errorf("expected subject %+v, got %+v", subject, statement.Subject)
+			}
+			build := statement.Predicate.BuildDefinition
+			if !reflect.DeepEqual(build.ResolvedDependencies, tc.dependencies) {
+				t.Errorf("expected dependencies %+v, got %+v", tc.dependencies, build.ResolvedDependencies)
+			}
+			if !reflect.DeepEqual(build.ExternalParameters.Transforms, tc.transforms) {
+				t.Errorf("expected transforms %v, got %v", tc.transforms, build.ExternalParameters.Transforms)
+			}
+			if build.ExternalParameters.Version != tc.cp.Version {
+				t.Errorf("unexpected external parameters %+v", build.ExternalParameters)
+			}
+		})
+	}
+}
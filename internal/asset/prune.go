@@ -0,0 +1,81 @@
+package asset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+// PruneStaleVersions removes the namespace, connector and version folders of
+// the downloads, extracts and outputs folders that are not backed by one of
+// connPkgs, so that versions removed from ndc-hub are not published again.
+// The removed folders are returned, with dryRun they are only listed.
+func PruneStaleVersions(connPkgs []ndchub.ConnectorPackaging, dryRun bool) ([]string, error) {
+	current := make(map[string]bool)
+	for _, cp := range connPkgs {
+		current[cp.Namespace] = true
+		current[filepath.Join(cp.Namespace, cp.Name)] = true
+		current[filepath.Join(cp.Namespace, cp.Name, cp.Version)] = true
+	}
+
+	var stale []string
+	for _, root := range []string{DownloadsFolderPath, ExtractsFolderPath, OutputFolderPath} {
+		folders, err := staleFolders(root, "", 0, current)
+		if err != nil {
+			return nil, err
+		}
+		for _, folder := range folders {
+			// the schemas of the index files sit next to the namespaces
+			if folder != schemasFolder() {
+				stale = append(stale, folder)
+			}
+		}
+	}
+	sort.Strings(stale)
+
+	if dryRun {
+		return stale, nil
+	}
+	for _, folder := range stale {
+		if err := os.RemoveAll(folder); err != nil {
+			return nil, fmt.Errorf("error removing %s: %w", folder, err)
+		}
+	}
+	return stale, nil
+}
+
+// staleFolders walks the namespace/name/version levels under root, returning
+// the first folder on each path that is not current. Files are kept, as the
+// top levels of the outputs folder hold the index and manifests.
+func staleFolders(root, rel string, depth int, current map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, rel))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		entryRel := filepath.Join(rel, entry.Name())
+		if !current[entryRel] {
+			stale = append(stale, filepath.Join(root, entryRel))
+			continue
+		}
+		if depth < 2 {
+			folders, err := staleFolders(root, entryRel, depth+1, current)
+			if err != nil {
+				return nil, err
+			}
+			stale = append(stale, folders...)
+		}
+	}
+	return stale, nil
+}
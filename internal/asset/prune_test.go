@@ -0,0 +1,71 @@
+package asset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+func TestPruneStaleVersions(t *testing.T) {
+	assets := t.TempDir()
+	defer func(downloads, extracts, outputs string) {
+		DownloadsFolderPath, ExtractsFolderPath, OutputFolderPath = downloads, extracts, outputs
+	}(DownloadsFolderPath, ExtractsFolderPath, OutputFolderPath)
+	DownloadsFolderPath = filepath.Join(assets, "downloads")
+	ExtractsFolderPath = filepath.Join(assets, "extracts")
+	OutputFolderPath = filepath.Join(assets, "outputs")
+
+	for _, rel := range []string{
+		"downloads/hasura/foo/v1/connector-definition.tar.gz",
+		"downloads/hasura/foo/v0/connector-definition.tar.gz",
+		"extracts/hasura/foo/v1/.hasura-connector/connector-metadata.yaml",
+		"extracts/hasura/bar/v1/.hasura-connector/connector-metadata.yaml",
+		"outputs/index.json",
+		"outputs/schemas/index.schema.v1.json",
+		"outputs/hasura/foo/v1/connector-definition.tar.gz",
+		"outputs/acme/baz/v1/connector-definition.tar.gz",
+	} {
+		p := filepath.Join(assets, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connPkgs := []ndchub.ConnectorPackaging{{Namespace: "hasura", Name: "foo", Version: "v1"}}
+	expected := []string{
+		filepath.Join(assets, "downloads/hasura/foo/v0"),
+		filepath.Join(assets, "extracts/hasura/bar"),
+		filepath.Join(assets, "outputs/acme"),
+	}
+
+	stale, err := PruneStaleVersions(connPkgs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stale, expected) {
+		t.Fatalf("unexpected stale folders\n got: %q\nwant: %q", stale, expected)
+	}
+	if _, err := os.Stat(expected[0]); err != nil {
+		t.Fatalf("expected a dry run to keep the stale folders: %v", err)
+	}
+
+	if _, err := PruneStaleVersions(connPkgs, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, folder := range expected {
+		if _, err := os.Stat(folder); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", folder)
+		}
+	}
+	for _, rel := range []string{"outputs/index.json", "outputs/schemas", "outputs/hasura/foo/v1", "extracts/hasura/foo/v1"} {
+		if _, err := os.Stat(filepath.Join(assets, rel)); err != nil {
+			t.Errorf("expected %s to be kept: %v", rel, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package asset
+
+import (
+	"log/slog"
+
+	"github.com/hasura/ddn-assets/internal/fetch"
+	"github.com/hasura/ddn-assets/internal/tracing"
+)
+
+// Run configures a run of the stages, which are each given the same run. Its
+// zero value downloads from the network without restrictions or cache, logs
+// through slog.Default, and neither reports progress, traces nor keeps going.
+type Run struct {
+	// Fetchers open the artefacts by uri scheme, with a client enforcing
+	// DownloadPolicy, see fetch.NewClient. The http, https and oci fetchers
+	// without restrictions are used when nil.
+	Fetchers fetch.Fetchers
+	// Cache is the content-addressed cache shared by the connector tarball
+	// and cli plugin downloads. Downloads are not cached when it is nil.
+	Cache *Cache
+	// Offline makes the downloads resolve artefacts from Cache and MirrorDir
+	// only, without making any network request.
+	Offline bool
+	// MirrorDir is a pre-populated directory holding artefacts at
+	// <MirrorDir>/<host>/<path of the uri>.
+	MirrorDir string
+	// DownloadPolicy restricts the uris artefacts are downloaded from. Any
+	// uri is allowed when it is nil.
+	DownloadPolicy *DownloadPolicy
+	// ExtractLimits are applied when extracting the connector tarballs,
+	// DefaultExtractLimits when nil.
+	ExtractLimits *ExtractLimits
+	// Logger is what the stages log with, slog.Default when nil.
+	Logger *slog.Logger
+	// Progress is what the stages report progress to, nothing is reported
+	// when it is nil.
+	Progress ProgressReporter
+	// Tracer records the spans of the stages, nothing is traced when it is
+	// nil.
+	Tracer *tracing.Tracer
+	// KeepGoing collects the failures of connector versions, so that the
+	// stages carry on with the other versions. The stages stop at the first
+	// failure when it is nil.
+	KeepGoing *Failures
+}
+
+func (r *Run) logger() *slog.Logger {
+	if r.Logger == nil {
+		return slog.Default()
+	}
+	return r.Logger
+}
+
+func (r *Run) fetchers() fetch.Fetchers {
+	if r.Fetchers == nil {
+		return fetch.NewFetchers(nil)
+	}
+	return r.Fetchers
+}
+
+func (r *Run) progress() ProgressReporter {
+	if r.Progress == nil {
+		return nopProgress{}
+	}
+	return r.Progress
+}
+
+func (r *Run) extractLimits() ExtractLimits {
+	if r.ExtractLimits == nil {
+		return DefaultExtractLimits
+	}
+	return *r.ExtractLimits
+}
@@ -0,0 +1,249 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
+	"github.com/hasura/ddn-assets/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+// SBOMs are CycloneDX documents, see https://cyclonedx.org/docs/1.5/json/
+
+const sbomFileName = "sbom.cdx.json"
+
+type SBOM struct {
+	BOMFormat   string          `json:"bomFormat"`
+	SpecVersion string          `json:"specVersion"`
+	Version     int             `json:"version"`
+	Metadata    SBOMMetadata    `json:"metadata"`
+	Components  []SBOMComponent `json:"components"`
+}
+
+type SBOMMetadata struct {
+	Tools     SBOMTools      `json:"tools"`
+	Component *SBOMComponent `json:"component,omitempty"`
+}
+
+type SBOMTools struct {
+	Components []SBOMComponent `json:"components"`
+}
+
+type SBOMComponent struct {
+	BOMRef             string                  `json:"bom-ref,omitempty"`
+	Type               string                  `json:"type"`
+	Name               string                  `json:"name"`
+	Version            string                  `json:"version,omitempty"`
+	Hashes             []SBOMHash              `json:"hashes,omitempty"`
+	ExternalReferences []SBOMExternalReference `json:"externalReferences,omitempty"`
+	Properties         []SBOMProperty          `json:"properties,omitempty"`
+}
+
+type SBOMHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type SBOMExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type SBOMProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func newSBOM(component *SBOMComponent, components []SBOMComponent) *SBOM {
+	return &SBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: SBOMMetadata{
+			Tools: SBOMTools{Components: []SBOMComponent{
+				{Type: "application", Name: "ddn-assets", Version: version.Get()},
+			}},
+			Component: component,
+		},
+		Components: components,
+	}
+}
+
+// dockerImagesOf returns the docker images a connector-metadata.yaml refers
+// to, from the packaging definition and a Docker cli plugin.
+func dockerImagesOf(connMetadataFilePath string) ([]string, error) {
+	data, err := os.ReadFile(connMetadataFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata struct {
+		PackagingDefinition struct {
+			DockerImage string `yaml:"dockerImage"`
+		} `yaml:"packagingDefinition"`
+	}
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	var connMetadata ConnectorMetadataYAML
+	if err := yaml.Unmarshal(data, &connMetadata); err != nil {
+		return nil, err
+	}
+
+	var images []string
+	if metadata.PackagingDefinition.DockerImage != "" {
+		images = append(images, metadata.PackagingDefinition.DockerImage)
+	}
+	if docker, ok := connMetadata.CLIPlugin.(*DockerCLIPluginDefinition); ok && docker.DockerImage != "" {
+		images = append(images, docker.DockerImage)
+	}
+	return images, nil
+}
+
+// connectorVersionSBOMComponents lists the components of a connector version.
+// When extractBinaries is set the cli plugins are published as the binaries
+// unpacked from their archives, so they are listed with the checksum of the
+// binary, the archive checksum being kept as a property.
+func connectorVersionSBOMComponents(cp ndchub.ConnectorPackaging, extractBinaries bool) ([]SBOMComponent, error) {
+	slug := fmt.Sprintf("%s/%s/%s", cp.Namespace, cp.Name, cp.Version)
+	components := []SBOMComponent{
+		{
+			BOMRef:  slug + "#connector-definition",
+			Type:    "file",
+			Name:    connectorDefinitionTarballName,
+			Version: cp.Version,
+			Hashes:  []SBOMHash{{Alg: "SHA-256", Content: cp.Checksum.Value}},
+			ExternalReferences: []SBOMExternalReference{
+				{Type: "distribution", URL: cp.URI},
+			},
+			Properties: []SBOMProperty{
+				{Name: "hasura:connector", Value: fmt.Sprintf("%s/%s", cp.Namespace, cp.Name)},
+			},
+		},
+	}
+
+	connMetadataFilePath := connectorMetadataFilePath(cp.Namespace, cp.Name, cp.Version)
+	cliPlugin, _, err := readBinaryInlineCLIPlugin(connMetadataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if cliPlugin != nil {
+		for _, p := range cliPlugin.Platforms {
+			component := SBOMComponent{
+				BOMRef:  fmt.Sprintf("%s#cli-plugin/%s", slug, p.Selector),
+				Type:    "application",
+				Name:    p.Bin,
+				Version: cp.Version,
+				Hashes:  []SBOMHash{{Alg: "SHA-256", Content: p.SHA256}},
+				ExternalReferences: []SBOMExternalReference{
+					{Type: "distribution", URL: p.URI},
+				},
+				Properties: []SBOMProperty{
+					{Name: "hasura:connector", Value: fmt.Sprintf("%s/%s", cp.Namespace, cp.Name)},
+					{Name: "hasura:cli-plugin:selector", Value: p.Selector},
+				},
+			}
+			if extractBinaries {
+				binPath := cliPluginBinaryPath(cp.Namespace, cp.Name, cp.Version, p.Selector, p.Bin)
+				sha, err := getSHAIfFileExists(binPath)
+				if err != nil {
+					return nil, fmt.Errorf("error reading cli plugin binary %s: %w", binPath, err)
+				}
+				component.Hashes = []SBOMHash{{Alg: "SHA-256", Content: sha}}
+				component.Properties = append(component.Properties, SBOMProperty{Name: "hasura:cli-plugin:archive-sha256", Value: p.SHA256})
+			}
+			components = append(components, component)
+		}
+	}
+
+	images, err := dockerImagesOf(connMetadataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		components = append(components, SBOMComponent{
+			BOMRef: "docker:" + image,
+			Type:   "container",
+			Name:   image,
+		})
+	}
+	return components, nil
+}
+
+func writeSBOM(sbom *SBOM, sbomPath string) error {
+	sbomJson, err := json.MarshalIndent(sbom, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while marshalling sbom json")
+	}
+	err = os.WriteFile(sbomPath, sbomJson, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %s", sbomPath, err)
+	}
+	return nil
+}
+
+// WriteSBOMs writes an SBOM for every connector version. The SBOMs list the
+// connector definitions and cli plugins as they were published upstream, so
+// they have to be written before ApplyCLIPluginTransform rewrites their uris.
+func WriteSBOMs(run *Run, connPkgs []ndchub.ConnectorPackaging, extractBinaries bool) error {
+	return forEachVersion(run, StageSBOM, connPkgs, func(_ *tracing.Span, cp ndchub.ConnectorPackaging) error {
+		components, err := connectorVersionSBOMComponents(cp, extractBinaries)
+		if err != nil {
+			return fmt.Errorf("error listing sbom components for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+		}
+
+		destFolder := outputConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version)
+		err = os.MkdirAll(destFolder, 0777)
+		if err != nil {
+			return fmt.Errorf("error creating folder: %s %w", destFolder, err)
+		}
+
+		sbomPath := filepath.Join(destFolder, sbomFileName)
+		if err := run.KeepGoing.Wrote(cp, sbomPath); err != nil {
+			return err
+		}
+		return writeSBOM(newSBOM(&SBOMComponent{
+			BOMRef:  fmt.Sprintf("%s/%s/%s", cp.Namespace, cp.Name, cp.Version),
+			Type:    "application",
+			Name:    fmt.Sprintf("%s/%s", cp.Namespace, cp.Name),
+			Version: cp.Version,
+		}, components), sbomPath)
+	})
+}
+
+// WriteAggregateSBOM writes the SBOM covering the whole outputs folder, from
+// the SBOMs of the connector versions. It is written once the failures of the
+// run are final, so that it leaves out the versions that failed in any stage.
+func WriteAggregateSBOM(run *Run, connPkgs []ndchub.ConnectorPackaging) error {
+	// docker images are shared by many versions, but listed once
+	seen := make(map[string]bool)
+	components := []SBOMComponent{}
+	for _, cp := range run.KeepGoing.Remaining(connPkgs) {
+		sbomPath := filepath.Join(outputConnectorVersionFolder(cp.Namespace, cp.Name, cp.Version), sbomFileName)
+		data, err := os.ReadFile(sbomPath)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", sbomPath, err)
+		}
+		var sbom SBOM
+		if err := json.Unmarshal(data, &sbom); err != nil {
+			return fmt.Errorf("error parsing %s: %w", sbomPath, err)
+		}
+		for _, c := range sbom.Components {
+			if seen[c.BOMRef] {
+				continue
+			}
+			seen[c.BOMRef] = true
+			components = append(components, c)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].BOMRef < components[j].BOMRef
+	})
+
+	return writeSBOM(newSBOM(nil, components), filepath.Join(OutputFolderPath, sbomFileName))
+}
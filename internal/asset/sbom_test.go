@@ -0,0 +1,94 @@
+package asset
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+const testBinaryInlineMetadata = `packagingDefinition:
+  type: PrebuiltDockerImage
+  dockerImage: ghcr.io/hasura/foo:v1
+cliPlugin:
+  type: BinaryInline
+  platforms:
+    - selector: linux-amd64
+      uri: https://example.com/plugin-linux.tar.gz
+      sha256: %s
+      bin: plugin
+`
+
+func TestWriteSBOMs(t *testing.T) {
+	useTestAssetFolders(t)
+	run := &Run{KeepGoing: &Failures{}}
+
+	archiveSHA := strings.Repeat("ab", 32)
+	foo := ndchub.ConnectorPackaging{Namespace: "hasura", Name: "foo", Version: "v1", URI: "https://example.com/foo.tar.gz", Checksum: ndchub.Checksum{Type: "sha256", Value: "foo-sha"}}
+	bar := ndchub.ConnectorPackaging{Namespace: "hasura", Name: "bar", Version: "v1", URI: "https://example.com/bar.tar.gz", Checksum: ndchub.Checksum{Type: "sha256", Value: "bar-sha"}}
+	connPkgs := []ndchub.ConnectorPackaging{foo, bar}
+
+	writeTestConnectorMetadata(t, foo, fmt.Sprintf(testBinaryInlineMetadata, archiveSHA))
+	writeTestConnectorMetadata(t, bar, "packagingDefinition:\n  type: PrebuiltDockerImage\n  dockerImage: ghcr.io/hasura/bar:v1\n")
+	binPath := cliPluginBinaryPath(foo.Namespace, foo.Name, foo.Version, "linux-amd64", "plugin")
+	if err := os.MkdirAll(filepath.Dir(binPath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(binPath, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteSBOMs(run, connPkgs, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var sbom SBOM
+	readJSONFile(t, filepath.Join(outputConnectorVersionFolder(foo.Namespace, foo.Name, foo.Version), sbomFileName), &sbom)
+	if sbom.Metadata.Component == nil || sbom.Metadata.Component.BOMRef != "hasura/foo/v1" {
+		t.Errorf("unexpected sbom component %+v", sbom.Metadata.Component)
+	}
+	var refs []string
+	for _, c := range sbom.Components {
+		refs = append(refs, c.BOMRef)
+	}
+	expectedRefs := []string{"hasura/foo/v1#connector-definition", "hasura/foo/v1#cli-plugin/linux-amd64", "docker:ghcr.io/hasura/foo:v1"}
+	if !reflect.DeepEqual(refs, expectedRefs) {
+		t.Errorf("expected components %v, got %v", expectedRefs, refs)
+	}
+	if hashes := sbom.Components[0].Hashes; len(hashes) != 1 || hashes[0].Content != "foo-sha" {
+		t.Errorf("expected the checksum of the connector definition, got %+v", hashes)
+	}
+	plugin := sbom.Components[1]
+	binarySHA := fmt.Sprintf("%x", sha256.Sum256([]byte("binary")))
+	if len(plugin.Hashes) != 1 || plugin.Hashes[0].Content != binarySHA {
+		t.Errorf("expected the checksum of the extracted binary, got %+v", plugin.Hashes)
+	}
+	if !reflect.DeepEqual(plugin.Properties[len(plugin.Properties)-1], SBOMProperty{Name: "hasura:cli-plugin:archive-sha256", Value: archiveSHA}) {
+		t.Errorf("expected the checksum of the archive as a property, got %+v", plugin.Properties)
+	}
+
+	// bar fails in a later stage, the aggregate sbom leaves it out
+	run.KeepGoing.Add(StageOutput, bar, errors.New("boom"))
+	if err := WriteAggregateSBOM(run, connPkgs); err != nil {
+		t.Fatal(err)
+	}
+	var aggregate SBOM
+	readJSONFile(t, filepath.Join(OutputFolderPath, sbomFileName), &aggregate)
+	if aggregate.Metadata.Component != nil {
+		t.Errorf("expected no component for the aggregate sbom, got %+v", aggregate.Metadata.Component)
+	}
+	refs = nil
+	for _, c := range aggregate.Components {
+		refs = append(refs, c.BOMRef)
+	}
+	expectedRefs = []string{"docker:ghcr.io/hasura/foo:v1", "hasura/foo/v1#cli-plugin/linux-amd64", "hasura/foo/v1#connector-definition"}
+	if !reflect.DeepEqual(refs, expectedRefs) {
+		t.Errorf("expected aggregate components %v, got %v", expectedRefs, refs)
+	}
+}
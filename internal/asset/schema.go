@@ -0,0 +1,252 @@
+package asset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The index files are the contract with DDN clients, their JSON Schemas are
+// generated from the Go types and published next to them. The schema version
+// has to be bumped on a breaking change, see CheckSchemaCompatibility.
+const IndexSchemaVersion = 1
+
+// Schema is the subset of JSON Schema the index files are described with.
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+type indexDocument struct {
+	name  string
+	value any
+}
+
+// indexDocuments are the index files that get a schema, named after it.
+var indexDocuments = []indexDocument{
+	{"index", Index{}},
+	{"connectors", ConnectorsIndex{}},
+	{"connector-index", ConnectorIndex{}},
+}
+
+func schemaFileName(name string) string {
+	return fmt.Sprintf("%s.schema.v%d.json", name, IndexSchemaVersion)
+}
+
+func schemasFolder() string {
+	return filepath.Join(OutputFolderPath, "schemas")
+}
+
+// IndexSchemas returns the schemas of the index files keyed by their file
+// name.
+func IndexSchemas() (map[string]*Schema, error) {
+	schemas := make(map[string]*Schema)
+	for _, doc := range indexDocuments {
+		fileName := schemaFileName(doc.name)
+		schema, err := schemaOf(reflect.TypeOf(doc.value))
+		if err != nil {
+			return nil, fmt.Errorf("error generating %s: %w", fileName, err)
+		}
+		schema.SchemaURI = "https://json-schema.org/draft/2020-12/schema"
+		schema.ID = fileName
+		schema.Title = doc.name
+		schemas[fileName] = schema
+	}
+	return schemas, nil
+}
+
+// schemaOf returns the schema of the json encoding of t. Slices and maps are
+// arrays and objects, the index files never write them as null.
+func schemaOf(t reflect.Type) (*Schema, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		if err := addStructFields(schema, t); err != nil {
+			return nil, err
+		}
+		sort.Strings(schema.Required)
+		return schema, nil
+	default:
+		return nil, fmt.Errorf("no json schema for %s", t)
+	}
+}
+
+// addStructFields adds the fields of a struct the way encoding/json marshals
+// them, including the fields of embedded structs.
+func addStructFields(schema *Schema, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			if err := addStructFields(schema, f.Type); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		property, err := schemaOf(f.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		schema.Properties[name] = property
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return nil
+}
+
+// WriteIndexSchemas writes the schemas of the index files to the outputs
+// folder.
+func WriteIndexSchemas() error {
+	if err := os.MkdirAll(schemasFolder(), 0777); err != nil {
+		return fmt.Errorf("error creating folder: %s %w", schemasFolder(), err)
+	}
+	schemas, err := IndexSchemas()
+	if err != nil {
+		return err
+	}
+	for fileName, schema := range schemas {
+		if err := writeJSON(filepath.Join(schemasFolder(), fileName), schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a decoded json document against the schema and returns its
+// problems, for the index files to be checked against the published schemas.
+func (s *Schema) Validate(doc any) []string {
+	var problems []string
+	s.validate(doc, "$", &problems)
+	return problems
+}
+
+func (s *Schema) validate(doc any, path string, problems *[]string) {
+	if !jsonTypeMatches(s.Type, doc) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s", path, s.Type))
+		return
+	}
+
+	switch value := doc.(type) {
+	case []any:
+		for i, item := range value {
+			s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing required property %s", path, name))
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := s.Properties[key]; ok {
+				property.validate(value[key], path+"."+key, problems)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(value[key], path+"."+key, problems)
+			}
+		}
+	}
+}
+
+func jsonTypeMatches(schemaType string, doc any) bool {
+	switch value := doc.(type) {
+	case string:
+		return schemaType == "string"
+	case bool:
+		return schemaType == "boolean"
+	case float64:
+		return schemaType == "number" || (schemaType == "integer" && value == float64(int64(value)))
+	case []any:
+		return schemaType == "array"
+	case map[string]any:
+		return schemaType == "object"
+	default:
+		return false
+	}
+}
+
+// CheckSchemaCompatibility lists the changes from a published schema that
+// break clients reading documents of the new schema: removed properties,
+// properties that are no longer required and changed types.
+func CheckSchemaCompatibility(published, current *Schema) []string {
+	var breaking []string
+	checkSchemaCompatibility(published, current, "$", &breaking)
+	return breaking
+}
+
+func checkSchemaCompatibility(published, current *Schema, path string, breaking *[]string) {
+	if current == nil {
+		*breaking = append(*breaking, fmt.Sprintf("%s: removed", path))
+		return
+	}
+	if published.Type != current.Type {
+		*breaking = append(*breaking, fmt.Sprintf("%s: type changed from %s to %s", path, published.Type, current.Type))
+		return
+	}
+
+	if published.Items != nil {
+		checkSchemaCompatibility(published.Items, current.Items, path+"[]", breaking)
+	}
+	if published.AdditionalProperties != nil {
+		checkSchemaCompatibility(published.AdditionalProperties, current.AdditionalProperties, path+".*", breaking)
+	}
+
+	for _, name := range published.Required {
+		if !containsString(current.Required, name) {
+			*breaking = append(*breaking, fmt.Sprintf("%s.%s: no longer required", path, name))
+		}
+	}
+	names := make([]string, 0, len(published.Properties))
+	for name := range published.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checkSchemaCompatibility(published.Properties[name], current.Properties[name], path+"."+name, breaking)
+	}
+}
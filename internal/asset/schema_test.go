@@ -0,0 +1,116 @@
+package asset
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateSchemas = flag.Bool("update-schemas", false, "update the published schema snapshots in testdata/schemas")
+
+// TestSchemaCompatibility fails on a breaking change of the index schemas.
+// Changes that are compatible need the snapshots to be updated with:
+//
+//	go test ./internal/asset -run TestSchemaCompatibility -update-schemas
+//
+// A breaking change needs IndexSchemaVersion to be bumped instead.
+func TestSchemaCompatibility(t *testing.T) {
+	schemas, err := IndexSchemas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fileName, current := range schemas {
+		snapshot := filepath.Join("testdata", "schemas", fileName)
+		if *updateSchemas {
+			if err := os.MkdirAll(filepath.Dir(snapshot), 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeJSON(snapshot, current); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		data, err := os.ReadFile(snapshot)
+		if err != nil {
+			t.Fatalf("no published schema for %s, run with -update-schemas to add it: %v", fileName, err)
+		}
+		var published Schema
+		if err := json.Unmarshal(data, &published); err != nil {
+			t.Fatal(err)
+		}
+
+		if breaking := CheckSchemaCompatibility(&published, current); len(breaking) > 0 {
+			t.Errorf("breaking changes to %s, bump IndexSchemaVersion:\n  %s", fileName, strings.Join(breaking, "\n  "))
+			continue
+		}
+		if !reflect.DeepEqual(&published, current) {
+			t.Errorf("%s changed compatibly, run with -update-schemas to update its snapshot", fileName)
+		}
+	}
+}
+
+// readPublishedSchema reads the snapshot of a published schema.
+func readPublishedSchema(t *testing.T, name string) *Schema {
+	t.Helper()
+	var schema Schema
+	readJSONFile(t, filepath.Join("testdata", "schemas", schemaFileName(name)), &schema)
+	return &schema
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := readPublishedSchema(t, "index")
+
+	valid := `{"total_connectors": 1, "connectors": [{"namespace": "hasura", "name": "foo", "latest_version": "v1"}], "connector_versions": {"hasura/foo": ["v1"]}}`
+	var doc any
+	if err := json.Unmarshal([]byte(valid), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if problems := schema.Validate(doc); len(problems) > 0 {
+		t.Errorf("expected a valid index, got %q", problems)
+	}
+
+	invalid := `{"total_connectors": 1.5, "connectors": [{"namespace": "hasura", "latest_version": 1}], "connector_versions": {"hasura/foo": "v1", "hasura/bar": null}}`
+	if err := json.Unmarshal([]byte(invalid), &doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"$.connector_versions.hasura/bar: expected array",
+		"$.connector_versions.hasura/foo: expected array",
+		"$.connectors[0]: missing required property name",
+		"$.connectors[0].latest_version: expected string",
+		"$.total_connectors: expected integer",
+	}
+	if problems := schema.Validate(doc); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("unexpected problems\n got: %q\nwant: %q", problems, expected)
+	}
+
+	schemas, err := IndexSchemas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := schemas[schemaFileName("index")]
+	delete(current.Properties, "provenance")
+	current.Properties["total_connectors"] = &Schema{Type: "string"}
+	breaking := CheckSchemaCompatibility(schema, current)
+	expectedBreaking := []string{
+		"$.provenance: removed",
+		"$.total_connectors: type changed from integer to string",
+	}
+	if !reflect.DeepEqual(breaking, expectedBreaking) {
+		t.Errorf("unexpected breaking changes\n got: %q\nwant: %q", breaking, expectedBreaking)
+	}
+}
+
+func TestSchemaOfUnsupportedType(t *testing.T) {
+	type withChannel struct {
+		Updates chan string `json:"updates"`
+	}
+	if _, err := schemaOf(reflect.TypeOf(withChannel{})); err == nil || !strings.Contains(err.Error(), "no json schema for chan string") {
+		t.Errorf("expected an error for a channel field, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package asset
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hasura/ddn-assets/internal/fetch"
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/signature"
+	"github.com/hasura/ddn-assets/internal/tracing"
+	"golang.org/x/sync/errgroup"
+)
+
+// VerifyConnectorTarballSignatures checks the detached signatures of the
+// downloaded connector tarballs against the keys of their namespace. Tarballs
+// in namespaces without keys are not checked, and unsigned tarballs are only
+// refused in namespaces that require signatures.
+func VerifyConnectorTarballSignatures(run *Run, connPkgs []ndchub.ConnectorPackaging, policy *signature.Policy) (err error) {
+	stageSpan := run.Tracer.Start(nil, StageVerifySignatures)
+	defer func() { stageSpan.End(err) }()
+
+	var verify errgroup.Group
+	for _, cp := range run.KeepGoing.Remaining(connPkgs) {
+		keys, required := policy.Keys(cp.Namespace)
+		if len(keys) == 0 {
+			continue
+		}
+		if err := checkURI(run, uriSource(cp, "connector-packaging.json signature uri"), cp.SignatureURI()); err != nil {
+			if err = versionFailed(run, StageVerifySignatures, cp, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		verify.Go(func() error {
+			span := run.Tracer.Start(stageSpan, fmt.Sprintf("%s %s/%s %s", StageVerifySignatures, cp.Namespace, cp.Name, cp.Version), versionSpanAttributes(cp)...)
+			err := verifyConnectorTarballSignature(run, span, cp, keys, required)
+			span.End(err)
+			return versionFailed(run, StageVerifySignatures, cp, err)
+		})
+	}
+	return verify.Wait()
+}
+
+// verifyConnectorTarballSignature downloads and checks the signature of a
+// connector version, in span.
+func verifyConnectorTarballSignature(run *Run, span *tracing.Span, cp ndchub.ConnectorPackaging, keys []crypto.PublicKey, required bool) error {
+	sigPath := connectorSignatureDownloadPath(cp.Namespace, cp.Name, cp.Version)
+	// signatures carry no checksum, so always fetch a fresh copy, unless
+	// offline where the copy stored by fetch is the one to use
+	if !run.Offline {
+		_ = os.Remove(sigPath)
+	}
+	log := connectorVersionLogger(run, cp)
+	err := downloadFile(run, span, log, cp.SignatureURI(), sigPath, "")
+	if err != nil {
+		// only a missing signature means the tarball is unsigned, any other
+		// error could hide a signature that does not verify
+		if !fetch.IsNotFound(err) {
+			return fmt.Errorf("error downloading the signature for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+		}
+		if required {
+			return fmt.Errorf("unsigned connector tarball for %s/%s %s, namespace %s requires signatures: %w", cp.Namespace, cp.Name, cp.Version, cp.Namespace, err)
+		}
+		log.Warn("no signature found, skipping verification", "error", err)
+		return nil
+	}
+
+	sigData, err := os.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	sig, err := signature.DecodeSignature(sigData)
+	if err != nil {
+		return fmt.Errorf("%s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+	}
+
+	tarball, err := os.ReadFile(connectorTarballDownloadPath(cp.Namespace, cp.Name, cp.Version))
+	if err != nil {
+		return err
+	}
+	if err := signature.Verify(keys, tarball, sig); err != nil {
+		return fmt.Errorf("error verifying connector tarball for %s/%s %s: %w", cp.Namespace, cp.Name, cp.Version, err)
+	}
+	return nil
+}
+
+// isSignedOutput reports whether a file of the outputs folder gets a detached
+// signature.
+func isSignedOutput(path string) bool {
+	switch filepath.Base(path) {
+	case connectorDefinitionTarballName, filepath.Base(IndexJsonPath), filepath.Base(ConnectorsJsonPath), SHA256SumsFileName, ManifestJSONFileName:
+		return true
+	}
+	return false
+}
+
+// SignOutputs writes a detached signature next to every index file, connector
+// tarball and manifest of the outputs folder. It runs after the manifests are
+// written, so that a mirror can authenticate them.
+func SignOutputs(key crypto.Signer) error {
+	var sign errgroup.Group
+	err := filepath.WalkDir(OutputFolderPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isSignedOutput(path) {
+			return nil
+		}
+
+		sign.Go(func() error {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			sig, err := signature.Sign(key, data)
+			if err != nil {
+				return fmt.Errorf("error signing %s: %w", path, err)
+			}
+			return os.WriteFile(path+".sig", sig, 0644)
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking the outputs folder: %w", err)
+	}
+	return sign.Wait()
+}
+
+// RemoveOutputSignatures deletes the detached signatures of the outputs
+// folder, so that the signatures of an earlier run are not published next to
+// outputs they do not match when the outputs are not signed.
+func RemoveOutputSignatures() error {
+	err := filepath.WalkDir(OutputFolderPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".sig") || !isSignedOutput(strings.TrimSuffix(path, ".sig")) {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing the signatures of the outputs folder: %w", err)
+	}
+	return nil
+}
+
+// VerifyOutputs checks the detached signatures of every index file, manifest
+// and connector tarball in an outputs folder, reporting every missing or
+// invalid signature.
+func VerifyOutputs(outputsFolder string, keys []crypto.PublicKey) error {
+	var errs []error
+	signed := 0
+	err := filepath.WalkDir(outputsFolder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isSignedOutput(path) {
+			return nil
+		}
+		rel, _ := filepath.Rel(outputsFolder, path)
+
+		sigData, err := os.ReadFile(path + ".sig")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: missing signature", rel))
+			return nil
+		}
+		sig, err := signature.DecodeSignature(sigData)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", rel, err))
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := signature.Verify(keys, data, sig); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", rel, err))
+			return nil
+		}
+		signed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking the outputs folder: %w", err)
+	}
+	if signed == 0 && len(errs) == 0 {
+		return fmt.Errorf("no signed outputs found in %s", outputsFolder)
+	}
+	return errors.Join(errs...)
+}
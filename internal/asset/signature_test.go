@@ -0,0 +1,147 @@
+package asset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/signature"
+)
+
+// writeTestSignaturePolicy writes a signature policy with a key for the
+// namespaces, and loads it.
+func writeTestSignaturePolicy(t *testing.T, policy string) *signature.Policy {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder := t.TempDir()
+	if err := os.WriteFile(filepath.Join(folder, "key.pub"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "policy.yaml"), []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := signature.LoadPolicy(filepath.Join(folder, "policy.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestVerifyConnectorTarballSignatureErrors(t *testing.T) {
+	useTestAssetFolders(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/broken") {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	policy := writeTestSignaturePolicy(t, "namespaces:\n  hasura:\n    keys: [key.pub]\n  strict:\n    required: true\n    keys: [key.pub]\n")
+
+	tt := []struct {
+		Name          string
+		Namespace     string
+		URI           string
+		ExpectedError string
+	}{
+		{Name: "Unsigned", Namespace: "hasura", URI: server.URL + "/unsigned.tar.gz"},
+		{Name: "Unsigned in a namespace requiring signatures", Namespace: "strict", URI: server.URL + "/unsigned.tar.gz", ExpectedError: "requires signatures"},
+		{Name: "Signature server failing", Namespace: "hasura", URI: server.URL + "/broken.tar.gz", ExpectedError: "error downloading the signature"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			cp := ndchub.ConnectorPackaging{Namespace: tc.Namespace, Name: "foo", Version: "v1", URI: tc.URI}
+			if err := os.MkdirAll(filepath.Dir(connectorSignatureDownloadPath(cp.Namespace, cp.Name, cp.Version)), 0777); err != nil {
+				t.Fatal(err)
+			}
+			keys, required := policy.Keys(cp.Namespace)
+			err := verifyConnectorTarballSignature(&Run{}, nil, cp, keys, required)
+			if tc.ExpectedError == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.ExpectedError) {
+				t.Errorf("expected an error containing %q, got %v", tc.ExpectedError, err)
+			}
+		})
+	}
+}
+
+func TestSignOutputs(t *testing.T) {
+	defer func(outputs string) { OutputFolderPath = outputs }(OutputFolderPath)
+	OutputFolderPath = t.TempDir()
+	files := map[string]string{
+		"index.json": `{"total_connectors": 1}`,
+		"hasura/foo/v0.1.0/connector-definition.tar.gz":           "tarball",
+		"hasura/foo/v0.1.0/cli-plugins/linux-amd64/plugin.tar.gz": "plugin",
+	}
+	for rel, content := range files {
+		p := filepath.Join(OutputFolderPath, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteOutputManifest(OutputFolderPath, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignOutputs(key); err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"index.json", SHA256SumsFileName, ManifestJSONFileName, "hasura/foo/v0.1.0/connector-definition.tar.gz"} {
+		if _, err := os.Stat(filepath.Join(OutputFolderPath, filepath.FromSlash(rel)+".sig")); err != nil {
+			t.Errorf("expected %s to be signed: %v", rel, err)
+		}
+	}
+	if err := VerifyOutputs(OutputFolderPath, []crypto.PublicKey{pub}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := VerifyOutputManifest(OutputFolderPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected the signatures to be left out of the manifest, got:\n%s", report)
+	}
+
+	if err := RemoveOutputSignatures(); err != nil {
+		t.Fatal(err)
+	}
+	sigs, err := filepath.Glob(filepath.Join(OutputFolderPath, "*.sig"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != 0 {
+		t.Errorf("expected the signatures to be removed, got %v", sigs)
+	}
+	if _, err := os.Stat(filepath.Join(OutputFolderPath, "hasura/foo/v0.1.0/connector-definition.tar.gz.sig")); !os.IsNotExist(err) {
+		t.Errorf("expected the connector tarball signature to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package asset
+
+import (
+	"fmt"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
+	"golang.org/x/sync/errgroup"
+)
+
+// Stages of the generation that process each connector version, in the
+// order they run.
+const (
+	StageDownload           = "download"
+	StageVerifySignatures   = "verify-signatures"
+	StageExtract            = "extract"
+	StageValidate           = "validate"
+	StageCLIPlugins         = "cli-plugins"
+	StageSBOM               = "sbom"
+	StageCLIPluginTransform = "cli-plugin-transform"
+	StageOutput             = "output"
+	StageProvenance         = "provenance"
+)
+
+// Sources downloadFile resolves an artefact from.
+const (
+	ArtefactExisting = "existing"
+	ArtefactCache    = "cache"
+	ArtefactMirror   = "mirror"
+	ArtefactNetwork  = "network"
+)
+
+// forEachVersion runs fn concurrently for every connector version as a stage,
+// reporting its progress. fn is given the span of the connector version, the
+// stage and each version being traced. In keep-going mode, the versions that
+// failed in an earlier stage are skipped and the failures of this one are
+// recorded instead of returned.
+func forEachVersion(run *Run, stage string, connPkgs []ndchub.ConnectorPackaging, fn func(span *tracing.Span, cp ndchub.ConnectorPackaging) error) error {
+	connPkgs = run.KeepGoing.Remaining(connPkgs)
+	p := run.progress()
+	p.StageStarted(stage, len(connPkgs))
+	defer p.StageFinished(stage)
+	stageSpan := run.Tracer.Start(nil, stage, tracing.Int64("versions", int64(len(connPkgs))))
+
+	var g errgroup.Group
+	for _, cp := range connPkgs {
+		g.Go(func() error {
+			span := run.Tracer.Start(stageSpan, fmt.Sprintf("%s %s/%s %s", stage, cp.Namespace, cp.Name, cp.Version), versionSpanAttributes(cp)...)
+			err := fn(span, cp)
+			span.End(err)
+			p.VersionDone(stage, cp, err)
+			return versionFailed(run, stage, cp, err)
+		})
+	}
+	err := g.Wait()
+	stageSpan.End(err)
+	return err
+}
+
+// versionFailed records the failure of a connector version in keep-going
+// mode and returns nil, so that the stage carries on. It returns err
+// otherwise.
+func versionFailed(run *Run, stage string, cp ndchub.ConnectorPackaging, err error) error {
+	if err == nil || run.KeepGoing == nil {
+		return err
+	}
+	run.KeepGoing.Add(stage, cp, err)
+	return nil
+}
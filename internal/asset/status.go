@@ -0,0 +1,191 @@
+package asset
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"gopkg.in/yaml.v3"
+)
+
+// VersionStatus is the status of a deprecated or yanked connector version.
+type VersionStatus struct {
+	Status string `json:"status" yaml:"status"`
+	Reason string `json:"reason,omitempty" yaml:"reason"`
+}
+
+// LoadVersionStatusOverrides reads a YAML file marking connector versions as
+// deprecated or yanked, for versions whose connector-packaging.json cannot be
+// changed:
+//
+//	hasura/neo4j/v0.0.6:
+//	  status: yanked
+//	  reason: the schema introspection is broken
+func LoadVersionStatusOverrides(path string) (map[string]VersionStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides map[string]VersionStatus
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("error parsing version status overrides %s: %w", path, err)
+	}
+	for version, status := range overrides {
+		if !isVersionStatus(status.Status) {
+			return nil, fmt.Errorf("unknown status %q of %s in %s", status.Status, version, path)
+		}
+	}
+	return overrides, nil
+}
+
+func isVersionStatus(status string) bool {
+	return status == ndchub.VersionStatusDeprecated || status == ndchub.VersionStatusYanked
+}
+
+// ApplyVersionStatusOverrides sets the status of the connector versions listed
+// in overrides, taking precedence over their connector-packaging.json.
+func ApplyVersionStatusOverrides(connPkgs []ndchub.ConnectorPackaging, overrides map[string]VersionStatus) error {
+	applied := make(map[string]bool)
+	for i := range connPkgs {
+		cp := &connPkgs[i]
+		key := fmt.Sprintf("%s/%s/%s", cp.Namespace, cp.Name, cp.Version)
+		if status, ok := overrides[key]; ok {
+			cp.Status = status.Status
+			cp.StatusReason = status.Reason
+			applied[key] = true
+		}
+	}
+
+	var unknown []string
+	for key := range overrides {
+		if !applied[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("version status overrides for unknown connector versions: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// VersionStatuses maps namespace/name/version to the status of the connector
+// versions that are deprecated or yanked.
+func VersionStatuses(connPkgs []ndchub.ConnectorPackaging) (map[string]VersionStatus, error) {
+	statuses := make(map[string]VersionStatus)
+	for _, cp := range connPkgs {
+		if cp.Status == "" {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%s", cp.Namespace, cp.Name, cp.Version)
+		if !isVersionStatus(cp.Status) {
+			return nil, fmt.Errorf("unknown status %q of %s", cp.Status, key)
+		}
+		statuses[key] = VersionStatus{Status: cp.Status, Reason: cp.StatusReason}
+	}
+	return statuses, nil
+}
+
+// ResolveLatestVersions replaces a yanked latest_version of a connector with
+// its newest stable version that is not yanked, falling back to its newest
+// pre-release, or with no version when all of them are yanked.
+func ResolveLatestVersions(connectors []Connector, connPkgs []ndchub.ConnectorPackaging) []Connector {
+	yanked := make(map[string]bool)
+	available := make(map[string][]string)
+	for _, cp := range connPkgs {
+		slug := fmt.Sprintf("%s/%s", cp.Namespace, cp.Name)
+		if cp.Status == ndchub.VersionStatusYanked {
+			yanked[slug+"/"+cp.Version] = true
+			continue
+		}
+		available[slug] = append(available[slug], cp.Version)
+	}
+
+	resolved := make([]Connector, len(connectors))
+	for i, c := range connectors {
+		slug := fmt.Sprintf("%s/%s", c.Namespace, c.Name)
+		if yanked[slug+"/"+c.LatestVersion] {
+			c.LatestVersion = newestVersion(available[slug])
+		}
+		resolved[i] = c
+	}
+	return resolved
+}
+
+// newestVersion returns the newest stable version, or the newest pre-release
+// when there is no stable version, or "" when there is no version at all.
+func newestVersion(versions []string) string {
+	newest := ""
+	for _, v := range versions {
+		if newest == "" || isPreRelease(newest) && !isPreRelease(v) {
+			newest = v
+			continue
+		}
+		if isPreRelease(v) && !isPreRelease(newest) {
+			continue
+		}
+		if compareVersions(v, newest) > 0 {
+			newest = v
+		}
+	}
+	return newest
+}
+
+func isPreRelease(version string) bool {
+	return strings.Contains(version, "-")
+}
+
+// compareVersions orders semver-like versions such as v1.2.3 and v1.2.3-rc.1,
+// comparing numeric parts as numbers. A pre-release sorts before its release.
+func compareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	aRelease, aPre, aHasPre := strings.Cut(a, "-")
+	bRelease, bPre, bHasPre := strings.Cut(b, "-")
+
+	if c := compareDotted(aRelease, bRelease); c != 0 {
+		return c
+	}
+	switch {
+	case aHasPre && !bHasPre:
+		return -1
+	case !aHasPre && bHasPre:
+		return 1
+	}
+	return compareDotted(aPre, bPre)
+}
+
+func compareDotted(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		if i >= len(aParts) {
+			return -1
+		}
+		if i >= len(bParts) {
+			return 1
+		}
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
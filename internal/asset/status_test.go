@@ -0,0 +1,84 @@
+package asset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/ddn-assets/internal/ndchub"
+)
+
+func TestVersionStatus(t *testing.T) {
+	overridesPath := filepath.Join(t.TempDir(), "version-status.yaml")
+	err := os.WriteFile(overridesPath, []byte(`
+hasura/neo4j/v0.0.10:
+  status: yanked
+  reason: broken introspection
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := LoadVersionStatusOverrides(overridesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connPkgs := []ndchub.ConnectorPackaging{
+		{Namespace: "hasura", Name: "neo4j", Version: "v0.0.9"},
+		{Namespace: "hasura", Name: "neo4j", Version: "v0.0.10"},
+		{Namespace: "hasura", Name: "neo4j", Version: "v0.0.10-rc.1"},
+		{Namespace: "hasura", Name: "neo4j", Version: "v0.0.8", Status: ndchub.VersionStatusDeprecated},
+		{Namespace: "hasura", Name: "foo", Version: "v1.0.0", Status: ndchub.VersionStatusYanked, StatusReason: "wrong tarball"},
+	}
+	if err := ApplyVersionStatusOverrides(connPkgs, overrides); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := VersionStatuses(connPkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedStatuses := map[string]VersionStatus{
+		"hasura/neo4j/v0.0.10": {Status: ndchub.VersionStatusYanked, Reason: "broken introspection"},
+		"hasura/neo4j/v0.0.8":  {Status: ndchub.VersionStatusDeprecated},
+		"hasura/foo/v1.0.0":    {Status: ndchub.VersionStatusYanked, Reason: "wrong tarball"},
+	}
+	if !reflect.DeepEqual(statuses, expectedStatuses) {
+		t.Errorf("unexpected statuses\n got: %+v\nwant: %+v", statuses, expectedStatuses)
+	}
+
+	connectors := ResolveLatestVersions([]Connector{
+		{Namespace: "hasura", Name: "neo4j", LatestVersion: "v0.0.10"},
+		{Namespace: "hasura", Name: "foo", LatestVersion: "v1.0.0"},
+	}, connPkgs)
+	expectedConnectors := []Connector{
+		{Namespace: "hasura", Name: "neo4j", LatestVersion: "v0.0.9"},
+		{Namespace: "hasura", Name: "foo", LatestVersion: ""},
+	}
+	if !reflect.DeepEqual(connectors, expectedConnectors) {
+		t.Errorf("unexpected latest versions\n got: %+v\nwant: %+v", connectors, expectedConnectors)
+	}
+
+	err = ApplyVersionStatusOverrides(connPkgs, map[string]VersionStatus{"hasura/bar/v1": {Status: ndchub.VersionStatusYanked}})
+	if err == nil {
+		t.Error("expected an error for an override of an unknown version")
+	}
+}
+
+func TestNewestVersion(t *testing.T) {
+	tt := []struct {
+		Versions []string
+		Expected string
+	}{
+		{Versions: []string{"v1.0.0", "v1.1.0-rc.1", "v0.9.0"}, Expected: "v1.0.0"},
+		{Versions: []string{"v1.1.0-rc.1", "v1.0.0", "v1.1.0"}, Expected: "v1.1.0"},
+		{Versions: []string{"v1.1.0-rc.1", "v1.1.0-rc.2", "v1.0.0-beta.1"}, Expected: "v1.1.0-rc.2"},
+		{Versions: nil, Expected: ""},
+	}
+	for _, tc := range tt {
+		if got := newestVersion(tc.Versions); got != tc.Expected {
+			t.Errorf("expected %s as the newest of %v, got %s", tc.Expected, tc.Versions, got)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package asset
+
+import (
+	"github.com/hasura/ddn-assets/internal/ndchub"
+	"github.com/hasura/ddn-assets/internal/tracing"
+)
+
+func versionSpanAttributes(cp ndchub.ConnectorPackaging) []tracing.Attribute {
+	return []tracing.Attribute{
+		tracing.String("connector.namespace", cp.Namespace),
+		tracing.String("connector.name", cp.Name),
+		tracing.String("connector.version", cp.Version),
+	}
+}
@@ -0,0 +1,71 @@
+package fetch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Policy restricts the requests the fetchers make. It is enforced on every
+// request rather than on the uri a fetcher is given only: redirects, registry
+// token endpoints and s3 endpoints are checked too, and addresses are checked
+// when connecting, after the host name is resolved.
+type Policy interface {
+	// CheckURL returns an error when the http or https url is not allowed.
+	CheckURL(u *url.URL) error
+	// CheckIP returns an error when connecting to ip is not allowed.
+	CheckIP(ip net.IP) error
+}
+
+// NewClient returns an http client for the fetchers that enforces p on every
+// request it makes and every address it connects to. Any request is allowed
+// when p is nil.
+func NewClient(p Policy) *http.Client {
+	return &http.Client{
+		Transport: &policyTransport{policy: p, next: newTransport(p)},
+	}
+}
+
+func newTransport(p Policy) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if p != nil {
+		// the address is checked as it is connected to, so that a host name
+		// cannot resolve to another address than the one checked
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("unexpected address %s", address)
+			}
+			return p.CheckIP(ip)
+		}
+	}
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// policyTransport applies the policy to every url requested, including the
+// ones a request is redirected to.
+type policyTransport struct {
+	policy Policy
+	next   http.RoundTripper
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.policy != nil {
+		if err := t.policy.CheckURL(req.URL); err != nil {
+			return nil, err
+		}
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,105 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Fetcher opens the artefact at a URI. Fetchers are registered per URI scheme.
+type Fetcher interface {
+	Fetch(uri *url.URL) (io.ReadCloser, error)
+}
+
+// Fetchers are the fetchers artefacts are opened with, keyed by the URI
+// scheme they fetch.
+type Fetchers map[string]Fetcher
+
+// NewFetchers returns the fetchers of the http, https and oci schemes, making
+// their requests with c. The s3 and file schemes are opt-in: the s3 fetcher is
+// configured from the environment, and file URIs read the local disk of the
+// build host.
+func NewFetchers(c *http.Client) Fetchers {
+	return Fetchers{
+		"http":  HTTPFetcher{Client: c},
+		"https": HTTPFetcher{Client: c},
+		"oci":   &OCIFetcher{Client: c},
+	}
+}
+
+// Open returns the content of the artefact at uri, using the fetcher of its
+// scheme.
+func (fetchers Fetchers) Open(uri string) (io.ReadCloser, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing uri %s: %w", uri, err)
+	}
+
+	f, ok := fetchers[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported uri scheme %q: %s", u.Scheme, uri)
+	}
+	return f.Fetch(u)
+}
+
+// StatusError is returned when a server answers with another status than
+// 200 OK.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code %d", e.StatusCode)
+}
+
+// IsNotFound reports whether err means that there is no artefact at the uri,
+// as opposed to the artefact being unreachable.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusNotFound
+	}
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// HTTPFetcher fetches http:// and https:// URIs with a plain GET.
+type HTTPFetcher struct {
+	// Client makes the requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+func (f HTTPFetcher) Fetch(uri *url.URL) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, uri.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c := f.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error downloading: %w", &StatusError{StatusCode: resp.StatusCode})
+	}
+	return resp.Body, nil
+}
+
+// FileFetcher fetches file:// URIs from the local disk, for connector builds
+// that are not published anywhere. Any file of the build host can be read
+// through it, so it is only to be used with a trusted registry.
+type FileFetcher struct{}
+
+func (FileFetcher) Fetch(uri *url.URL) (io.ReadCloser, error) {
+	if uri.Host != "" && uri.Host != "localhost" {
+		return nil, fmt.Errorf("file uri with a remote host is not supported: %s", uri)
+	}
+	return os.Open(uri.Path)
+}
@@ -0,0 +1,206 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, uri string) (string, error) {
+	t.Helper()
+	return readAllWith(t, NewFetchers(nil), uri)
+}
+
+func readAllWith(t *testing.T, fetchers Fetchers, uri string) (string, error) {
+	t.Helper()
+	rc, err := fetchers.Open(uri)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	return string(content), err
+}
+
+func TestFileFetcher(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "connector-definition.tar.gz")
+	if err := os.WriteFile(filePath, []byte("local build"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readAll(t, "file://"+filepath.ToSlash(filePath)); err == nil {
+		t.Error("expected file uris to be refused unless opted in")
+	}
+
+	fetchers := NewFetchers(nil)
+	fetchers["file"] = FileFetcher{}
+	content, err := readAllWith(t, fetchers, "file://"+filepath.ToSlash(filePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "local build" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := readAll(t, "ftp://example.com/connector-definition.tar.gz"); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
+
+func TestOCIFetcher(t *testing.T) {
+	blob := "connector definition"
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(blob)))
+
+	// a stand-in registry that asks for an anonymous token first
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "anonymous"})
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/hasura/ndc-foo/manifests/v1.0.0":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"layers": []map[string]any{
+					{"digest": "sha256:other", "annotations": map[string]string{"org.opencontainers.image.title": "README.md"}},
+					{"digest": digest, "annotations": map[string]string{"org.opencontainers.image.title": "connector-definition.tar.gz"}},
+				},
+			})
+		case "/v2/hasura/ndc-foo/blobs/" + digest:
+			_, _ = io.WriteString(w, blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	content, err := readAll(t, "oci://"+host+"/hasura/ndc-foo:v1.0.0#connector-definition.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != blob {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := readAll(t, "oci://"+host+"/hasura/ndc-foo:v1.0.0"); err == nil {
+		t.Error("expected an error when the layer is ambiguous")
+	}
+}
+
+func TestS3Fetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/connectors/hasura/ndc-foo/connector-definition.tar.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, "from the bucket")
+	}))
+	defer server.Close()
+
+	fetchers := NewFetchers(nil)
+	fetchers["s3"] = &S3Fetcher{Endpoint: server.URL, Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}
+
+	content, err := readAllWith(t, fetchers, "s3://connectors/hasura/ndc-foo/connector-definition.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "from the bucket" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := readAllWith(t, fetchers, "s3://connectors/missing.tar.gz"); err == nil {
+		t.Error("expected an error for a missing object")
+	}
+}
+
+// testPolicy refuses plain http urls and connections to one address.
+type testPolicy struct {
+	deniedIP net.IP
+}
+
+func (p testPolicy) CheckURL(u *url.URL) error {
+	if u.Scheme == "http" {
+		return fmt.Errorf("%s is not https", u)
+	}
+	return nil
+}
+
+func (p testPolicy) CheckIP(ip net.IP) error {
+	if ip.Equal(p.deniedIP) {
+		return fmt.Errorf("%s is denied", ip)
+	}
+	return nil
+}
+
+func TestPolicyRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "redirected")
+	}))
+	defer target.Close()
+
+	// the redirecting server listens on another loopback address than the
+	// target, so that connecting to the target alone can be denied
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("no second loopback address: %s", err)
+	}
+	redirecting := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/to-http":
+			http.Redirect(w, r, target.URL+"/tarball.tar.gz", http.StatusFound)
+		case "/to-loopback":
+			http.Redirect(w, r, "https://"+target.Listener.Addr().String()+"/tarball.tar.gz", http.StatusFound)
+		default:
+			_, _ = io.WriteString(w, "direct")
+		}
+	}))
+	redirecting.Listener.Close()
+	redirecting.Listener = listener
+	redirecting.StartTLS()
+	defer redirecting.Close()
+
+	client := NewClient(testPolicy{deniedIP: net.ParseIP("127.0.0.1")})
+	// the test certificate is not valid for 127.0.0.2, but is for example.com
+	tlsConfig := redirecting.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	tlsConfig.ServerName = "example.com"
+	client.Transport.(*policyTransport).next.(*http.Transport).TLSClientConfig = tlsConfig
+	fetchers := NewFetchers(client)
+
+	content, err := readAllWith(t, fetchers, redirecting.URL+"/tarball.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "direct" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := readAllWith(t, fetchers, redirecting.URL+"/to-http"); err == nil || !strings.Contains(err.Error(), "is not https") {
+		t.Errorf("expected the redirect to http to be refused, got %v", err)
+	}
+	if _, err := readAllWith(t, fetchers, redirecting.URL+"/to-loopback"); err == nil || !strings.Contains(err.Error(), "127.0.0.1 is denied") {
+		t.Errorf("expected the redirect to 127.0.0.1 to be refused, got %v", err)
+	}
+	if _, err := readAllWith(t, fetchers, target.URL+"/tarball.tar.gz"); err == nil {
+		t.Error("expected a plain http uri to be refused")
+	}
+}
@@ -0,0 +1,249 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// OCIFetcher fetches artefacts pushed to an OCI registry, addressed as
+// oci://<registry>/<repository>:<tag> or oci://<registry>/<repository>@<digest>.
+// An artefact with several layers needs the layer title as the URI fragment,
+// e.g. oci://ghcr.io/hasura/ndc-foo:v1.0.0#connector-definition.tar.gz.
+type OCIFetcher struct {
+	// PlainHTTP talks to the registry over http instead of https. Loopback
+	// registries, like a local stand-in, always use http.
+	PlainHTTP bool
+	// Client makes the requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+type ociManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+func (f *OCIFetcher) Fetch(uri *url.URL) (io.ReadCloser, error) {
+	repository, reference, err := parseOCIReference(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "https"
+	if f.PlainHTTP || isLoopbackHost(uri.Hostname()) {
+		scheme = "http"
+	}
+	base := &url.URL{Scheme: scheme, Host: uri.Host}
+
+	manifestResp, err := f.get(base.JoinPath("v2", repository, "manifests", reference), manifestMediaTypes)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching manifest of %s: %w", uri, err)
+	}
+	defer manifestResp.Body.Close()
+
+	var manifest ociManifest
+	if err := json.NewDecoder(manifestResp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("error decoding manifest of %s: %w", uri, err)
+	}
+
+	digest, err := selectOCILayer(manifest, uri.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", uri, err)
+	}
+
+	blobResp, err := f.get(base.JoinPath("v2", repository, "blobs", digest), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching blob %s of %s: %w", digest, uri, err)
+	}
+	return newDigestVerifier(blobResp.Body, digest)
+}
+
+func (f *OCIFetcher) client() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	return http.DefaultClient
+}
+
+// get requests u, retrying once with an anonymous bearer token when the
+// registry asks for one.
+func (f *OCIFetcher) get(u *url.URL, accept []string) (*http.Response, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accept {
+			req.Header.Add("Accept", a)
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := f.anonymousToken(challenge)
+		if err != nil {
+			return nil, err
+		}
+		req, err = newRequest()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err = f.client().Do(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+func (f *OCIFetcher) anonymousToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry authentication: %q", challenge)
+	}
+
+	values := make(map[string]string)
+	for _, param := range strings.Split(params, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok {
+			values[k] = strings.Trim(v, `"`)
+		}
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return "", fmt.Errorf("invalid registry authentication realm: %q", challenge)
+	}
+	query := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if values[k] != "" {
+			query.Set(k, values[k])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching registry token: status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+func parseOCIReference(uri *url.URL) (string, string, error) {
+	ref := strings.TrimPrefix(uri.Path, "/")
+	if ref == "" {
+		return "", "", fmt.Errorf("missing repository in oci uri: %s", uri)
+	}
+
+	if repository, digest, ok := strings.Cut(ref, "@"); ok {
+		return repository, digest, nil
+	}
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		return ref[:idx], ref[idx+1:], nil
+	}
+	return ref, "latest", nil
+}
+
+func selectOCILayer(manifest ociManifest, title string) (string, error) {
+	if title == "" {
+		if len(manifest.Layers) != 1 {
+			return "", fmt.Errorf("artefact has %d layers, select one with the layer title as the uri fragment", len(manifest.Layers))
+		}
+		return manifest.Layers[0].Digest, nil
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.Annotations["org.opencontainers.image.title"] == title {
+			return layer.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("no layer titled %q", title)
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// digestVerifier fails the read of a blob at EOF when its content does not
+// match the digest it was requested by.
+type digestVerifier struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func newDigestVerifier(rc io.ReadCloser, digest string) (io.ReadCloser, error) {
+	algorithm, expected, _ := strings.Cut(digest, ":")
+	if algorithm != "sha256" {
+		rc.Close()
+		return nil, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	return &digestVerifier{ReadCloser: rc, hash: sha256.New(), expected: expected}, nil
+}
+
+func (d *digestVerifier) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	d.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if got := hex.EncodeToString(d.hash.Sum(nil)); got != d.expected {
+			return n, fmt.Errorf("blob digest mismatch: expected sha256:%s, got sha256:%s", d.expected, got)
+		}
+	}
+	return n, err
+}
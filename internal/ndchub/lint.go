@@ -0,0 +1,388 @@
+package ndchub
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is a problem found in a file of the ndc-hub registry.
+type Diagnostic struct {
+	// File is relative to the ndc-hub checkout.
+	File    string
+	Line    int
+	Message string
+	// Warning marks a problem that does not make the registry invalid.
+	Warning bool
+}
+
+func (d Diagnostic) String() string {
+	message := d.Message
+	if d.Warning {
+		message = "warning: " + message
+	}
+	if d.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", d.File, d.Line, message)
+	}
+	return fmt.Sprintf("%s: %s", d.File, message)
+}
+
+// LintRegistry checks the layout of the registry folder of an ndc-hub
+// checkout and the content of its metadata.json and connector-packaging.json
+// files.
+func LintRegistry(ndcHubFolder string) ([]Diagnostic, error) {
+	registryFolder := filepath.Join(ndcHubFolder, "registry")
+	l := &registryLinter{root: ndcHubFolder}
+
+	connectorFolders := make(map[string]bool)
+	err := filepath.WalkDir(registryFolder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "aliased_connectors" {
+			return filepath.SkipDir
+		}
+
+		switch filepath.Base(path) {
+		case MetadataJSON:
+			connectorFolders[filepath.Dir(path)] = true
+			l.lintMetadata(path)
+		case ConnectorPackagingJSON:
+			if l.lintPackaging(registryFolder, path) {
+				connectorFolders[filepath.Dir(filepath.Dir(filepath.Dir(path)))] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error while walking the registry folder %w", err)
+	}
+
+	for connectorFolder := range connectorFolders {
+		if _, err := os.Stat(filepath.Join(connectorFolder, MetadataJSON)); err != nil {
+			l.add(filepath.Join(connectorFolder, MetadataJSON), 0, "missing metadata.json for a connector with releases")
+		}
+	}
+
+	sort.Slice(l.diagnostics, func(i, j int) bool {
+		if l.diagnostics[i].File != l.diagnostics[j].File {
+			return l.diagnostics[i].File < l.diagnostics[j].File
+		}
+		return l.diagnostics[i].Line < l.diagnostics[j].Line
+	})
+	return l.diagnostics, nil
+}
+
+type registryLinter struct {
+	root        string
+	diagnostics []Diagnostic
+}
+
+func (l *registryLinter) add(path string, line int, format string, args ...any) {
+	l.diagnose(path, line, false, format, args...)
+}
+
+func (l *registryLinter) warn(path string, line int, format string, args ...any) {
+	l.diagnose(path, line, true, format, args...)
+}
+
+func (l *registryLinter) diagnose(path string, line int, warning bool, format string, args ...any) {
+	rel, err := filepath.Rel(l.root, path)
+	if err != nil {
+		rel = path
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		File:    filepath.ToSlash(rel),
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+		Warning: warning,
+	})
+}
+
+// readJSON decodes a json file into v, reporting syntax and type errors, and
+// returns the line of every key path in the file. With strict set, a field v
+// does not model is reported as a warning: the registry may carry fields
+// ddn-assets does not know about yet.
+func (l *registryLinter) readJSON(path string, v any, strict bool) (map[string]int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.add(path, 0, "%v", err)
+		return nil, false
+	}
+
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			l.add(path, lineAt(data, syntaxErr.Offset), "invalid json: %v", err)
+		} else {
+			l.add(path, 0, "invalid json: %v", err)
+		}
+		return nil, false
+	}
+	lines, err := jsonKeyLines(data)
+	if err != nil {
+		l.add(path, 0, "invalid json: %v", err)
+		return nil, false
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			l.add(path, lines[typeErr.Field], "field %s must be a %s", typeErr.Field, typeErr.Type)
+		} else {
+			l.add(path, 0, "%v", err)
+		}
+		return lines, false
+	}
+
+	if strict {
+		for _, field := range unknownFields(reflect.TypeOf(v), lines) {
+			l.warn(path, lines[field], "unknown field %s", field)
+		}
+	}
+	return lines, true
+}
+
+// unknownFields returns the key paths of lines that t does not model, leaving
+// out the keys nested in an unknown field or in a map. Keys are matched to the
+// json names of the struct fields the way encoding/json matches them.
+func unknownFields(t reflect.Type, lines map[string]int) []string {
+	keys := make([]string, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	// a key is sorted after its parent, whose type is then known
+	sort.Strings(keys)
+
+	types := map[string]reflect.Type{"": t}
+	var unknown []string
+	for _, key := range keys {
+		parent, name := "", key
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			parent, name = key[:i], key[i+1:]
+		}
+		parentType, ok := types[parent]
+		if !ok {
+			continue
+		}
+		for parentType.Kind() == reflect.Pointer || parentType.Kind() == reflect.Slice || parentType.Kind() == reflect.Array {
+			parentType = parentType.Elem()
+		}
+		if parentType.Kind() != reflect.Struct {
+			continue
+		}
+		field, ok := jsonField(parentType, name)
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		types[key] = field.Type
+	}
+	return unknown
+}
+
+// jsonField returns the field of the struct type t that the json key name is
+// decoded into.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			if embedded, ok := jsonField(f.Type, name); ok {
+				return embedded, true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func (l *registryLinter) lintMetadata(path string) {
+	var metadata struct {
+		Overview struct {
+			Namespace     string `json:"namespace"`
+			LatestVersion string `json:"latest_version"`
+		} `json:"overview"`
+	}
+	lines, ok := l.readJSON(path, &metadata, false)
+	if !ok {
+		return
+	}
+
+	connectorFolder := filepath.Dir(path)
+	namespace := filepath.Base(filepath.Dir(connectorFolder))
+	switch metadata.Overview.Namespace {
+	case "":
+		l.add(path, lines["overview"], "missing overview.namespace")
+	case namespace:
+	default:
+		l.add(path, lines["overview.namespace"], "overview.namespace %q does not match the namespace folder %q", metadata.Overview.Namespace, namespace)
+	}
+
+	if metadata.Overview.LatestVersion == "" {
+		l.add(path, lines["overview"], "missing overview.latest_version")
+		return
+	}
+	releasesFolder := filepath.Join(connectorFolder, "releases")
+	if _, err := os.Stat(releasesFolder); err != nil {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(releasesFolder, metadata.Overview.LatestVersion, ConnectorPackagingJSON)); err != nil {
+		l.add(path, lines["overview.latest_version"], "overview.latest_version %s has no releases/%s/%s", metadata.Overview.LatestVersion, metadata.Overview.LatestVersion, ConnectorPackagingJSON)
+	}
+}
+
+// packagingFile is a connector-packaging.json file, along with the optional
+// fields of the registry that generating the assets does not need.
+type packagingFile struct {
+	ConnectorPackaging
+	// Test points at the configuration the connector is tested with in the
+	// ndc-hub CI.
+	Test *struct {
+		TestConfigPath string `json:"test_config_path"`
+	} `json:"test,omitempty"`
+}
+
+// lintPackaging reports whether the connector-packaging.json file is at the
+// expected place in the registry.
+func (l *registryLinter) lintPackaging(registryFolder, path string) bool {
+	// path looks like this: registry/hasura/turso/releases/v0.1.0/connector-packaging.json
+	rel, err := filepath.Rel(registryFolder, path)
+	if err != nil {
+		l.add(path, 0, "%v", err)
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 5 || parts[2] != "releases" {
+		l.add(path, 0, "expected at registry/<namespace>/<name>/releases/<version>/%s", ConnectorPackagingJSON)
+		return false
+	}
+	versionFolder := parts[3]
+
+	var packaging packagingFile
+	lines, ok := l.readJSON(path, &packaging, true)
+	if !ok {
+		return true
+	}
+	cp := packaging.ConnectorPackaging
+
+	switch cp.Version {
+	case "":
+		l.add(path, 1, "missing version")
+	case versionFolder:
+	default:
+		l.add(path, lines["version"], "version %q does not match the release folder %q", cp.Version, versionFolder)
+	}
+
+	if cp.URI == "" {
+		l.add(path, lineOr(lines, "uri", 1), "missing uri")
+	} else if u, err := url.Parse(cp.URI); err != nil || u.Scheme == "" {
+		l.add(path, lines["uri"], "uri %q is not an absolute uri", cp.URI)
+	}
+
+	switch {
+	case cp.Checksum.Type == "":
+		l.add(path, lineOr(lines, "checksum", 1), "missing checksum.type")
+	case cp.Checksum.Type != "sha256":
+		l.add(path, lines["checksum.type"], "unsupported checksum.type %q", cp.Checksum.Type)
+	}
+	if cp.Checksum.Value == "" {
+		l.add(path, lineOr(lines, "checksum", 1), "missing checksum.value")
+	} else if b, err := hex.DecodeString(cp.Checksum.Value); err != nil || len(b) != 32 {
+		l.add(path, lines["checksum.value"], "checksum.value %q is not a sha256 hex digest", cp.Checksum.Value)
+	}
+
+	switch cp.Status {
+	case "", VersionStatusDeprecated, VersionStatusYanked:
+	default:
+		l.add(path, lines["status"], "unknown status %q, expected %s or %s", cp.Status, VersionStatusDeprecated, VersionStatusYanked)
+	}
+	return true
+}
+
+func lineOr(lines map[string]int, key string, fallback int) int {
+	if line, ok := lines[key]; ok {
+		return line
+	}
+	return fallback
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// jsonKeyLines returns the line of every object key in a valid json document,
+// keyed by its dotted path.
+func jsonKeyLines(data []byte) (map[string]int, error) {
+	lines := make(map[string]int)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(prefix string) error
+	walk = func(prefix string) error {
+		tok, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			return nil
+		}
+
+		switch delim {
+		case '{':
+			for decoder.More() {
+				keyTok, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key := keyTok.(string)
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				lines[key] = lineAt(data, decoder.InputOffset())
+				if err := walk(key); err != nil {
+					return err
+				}
+			}
+		case '[':
+			for decoder.More() {
+				if err := walk(prefix); err != nil {
+					return err
+				}
+			}
+		}
+		// closing delimiter
+		_, err = decoder.Token()
+		return err
+	}
+
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
@@ -0,0 +1,119 @@
+package ndchub
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintRegistry(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	tt := []struct {
+		Name                string
+		Files               map[string]string
+		ExpectedDiagnostics []string
+	}{
+		{
+			Name: "Valid registry",
+			Files: map[string]string{
+				"registry/hasura/foo/metadata.json": `{"overview": {"namespace": "hasura", "latest_version": "v0.1.0"}}`,
+				"registry/hasura/foo/releases/v0.1.0/connector-packaging.json": `{
+  "version": "v0.1.0",
+  "uri": "https://github.com/hasura/ndc-foo/releases/download/v0.1.0/connector-definition.tgz",
+  "checksum": {"type": "sha256", "value": "` + sha + `"},
+  "source": {"hash": "abc"},
+  "test": {"test_config_path": "../../tests/test-config.json"}
+}`,
+				"registry/hasura/aliased_connectors/bar/releases/v1/connector-packaging.json": `{}`,
+			},
+		},
+		{
+			Name: "Broken packaging",
+			Files: map[string]string{
+				"registry/hasura/foo/metadata.json": `{"overview": {"namespace": "hasura", "latest_version": "v0.1.0"}}`,
+				"registry/hasura/foo/releases/v0.1.0/connector-packaging.json": `{
+  "version": "v0.1",
+  "checksum": {
+    "type": "md5",
+    "value": ""
+  }
+}`,
+			},
+			ExpectedDiagnostics: []string{
+				`registry/hasura/foo/releases/v0.1.0/connector-packaging.json:1: missing uri`,
+				`registry/hasura/foo/releases/v0.1.0/connector-packaging.json:2: version "v0.1" does not match the release folder "v0.1.0"`,
+				`registry/hasura/foo/releases/v0.1.0/connector-packaging.json:3: missing checksum.value`,
+				`registry/hasura/foo/releases/v0.1.0/connector-packaging.json:4: unsupported checksum.type "md5"`,
+			},
+		},
+		{
+			Name: "Unknown field and invalid json",
+			Files: map[string]string{
+				"registry/hasura/foo/metadata.json":                           `{"overview": {"namespace": "hasura", "latest_version": "v2"}}`,
+				"registry/hasura/foo/releases/v1/connector-packaging.json":    "{\n  \"version\": \"v1\",\n  \"url\": \"x\",\n  \"uri\": \"https://example.com/a.tgz\",\n  \"checksum\": {\"type\": \"sha256\", \"value\": \"" + sha + "\"}\n}",
+				"registry/hasura/foo/releases/v2/connector-packaging.json":    "{\n  \"version\": \"v2\",\n}",
+				"registry/hasura/foo/releases/connector-packaging.json":       `{}`,
+				"registry/hasura/orphan/releases/v1/connector-packaging.json": `{"version": "v1", "uri": "https://example.com/a.tgz", "checksum": {"type": "sha256", "value": "` + sha + `"}}`,
+			},
+			ExpectedDiagnostics: []string{
+				`registry/hasura/foo/releases/connector-packaging.json: expected at registry/<namespace>/<name>/releases/<version>/connector-packaging.json`,
+				`registry/hasura/foo/releases/v1/connector-packaging.json:3: warning: unknown field url`,
+				`registry/hasura/foo/releases/v2/connector-packaging.json:3: invalid json: invalid character '}' looking for beginning of object key string`,
+				`registry/hasura/orphan/metadata.json: missing metadata.json for a connector with releases`,
+			},
+		},
+		{
+			Name: "Several unknown fields",
+			Files: map[string]string{
+				"registry/hasura/foo/metadata.json":                        `{"overview": {"namespace": "hasura", "latest_version": "v1"}}`,
+				"registry/hasura/foo/releases/v1/connector-packaging.json": "{\n  \"version\": \"v1\",\n  \"url\": \"x\",\n  \"uri\": \"https://example.com/a.tgz\",\n  \"checksum\": {\n    \"type\": \"sha256\",\n    \"algorithm\": \"sha256\",\n    \"value\": \"" + sha + "\"\n  },\n  \"extra\": {\"nested\": true}\n}",
+			},
+			ExpectedDiagnostics: []string{
+				`registry/hasura/foo/releases/v1/connector-packaging.json:3: warning: unknown field url`,
+				`registry/hasura/foo/releases/v1/connector-packaging.json:7: warning: unknown field checksum.algorithm`,
+				`registry/hasura/foo/releases/v1/connector-packaging.json:10: warning: unknown field extra`,
+			},
+		},
+		{
+			Name: "Metadata disagrees with the layout",
+			Files: map[string]string{
+				"registry/hasura/foo/metadata.json":                        "{\n  \"overview\": {\n    \"namespace\": \"other\",\n    \"latest_version\": \"v9\"\n  }\n}",
+				"registry/hasura/foo/releases/v1/connector-packaging.json": `{"version": "v1", "uri": "https://example.com/a.tgz", "checksum": {"type": "sha256", "value": "` + sha + `"}, "status": "broken"}`,
+			},
+			ExpectedDiagnostics: []string{
+				`registry/hasura/foo/metadata.json:3: overview.namespace "other" does not match the namespace folder "hasura"`,
+				`registry/hasura/foo/metadata.json:4: overview.latest_version v9 has no releases/v9/connector-packaging.json`,
+				`registry/hasura/foo/releases/v1/connector-packaging.json:1: unknown status "broken", expected deprecated or yanked`,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ndcHub := t.TempDir()
+			for name, content := range tc.Files {
+				path := filepath.Join(ndcHub, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			diagnostics, err := LintRegistry(ndcHub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range diagnostics {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tc.ExpectedDiagnostics) {
+				t.Errorf("unexpected diagnostics\n got: %q\nwant: %q", got, tc.ExpectedDiagnostics)
+			}
+		})
+	}
+}
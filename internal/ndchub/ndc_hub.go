@@ -21,14 +21,42 @@ type Source struct {
 	Hash string `json:"hash"`
 }
 
+// Signature points at the detached signature of the connector tarball.
+type Signature struct {
+	URI string `json:"uri"`
+}
+
+// Version statuses a connector version can be marked with. Versions without a
+// status are active.
+const (
+	VersionStatusDeprecated = "deprecated"
+	VersionStatusYanked     = "yanked"
+)
+
 type ConnectorPackaging struct {
 	Namespace string `json:"-"`
 	Name      string `json:"-"`
 
-	Version  string   `json:"version"`
-	URI      string   `json:"uri"`
-	Checksum Checksum `json:"checksum"`
-	Source   Source   `json:"source"`
+	Version   string     `json:"version"`
+	URI       string     `json:"uri"`
+	Checksum  Checksum   `json:"checksum"`
+	Source    Source     `json:"source"`
+	Signature *Signature `json:"signature,omitempty"`
+
+	// Status marks a deprecated or yanked version, with a reason for users.
+	// Yanked versions are still published, but never resolved as the latest
+	// version of a connector.
+	Status       string `json:"status,omitempty"`
+	StatusReason string `json:"status_reason,omitempty"`
+}
+
+// SignatureURI returns the uri of the detached signature of the connector
+// tarball, which defaults to the tarball uri with a .sig suffix.
+func (cp *ConnectorPackaging) SignatureURI() string {
+	if cp.Signature != nil && cp.Signature.URI != "" {
+		return cp.Signature.URI
+	}
+	return cp.URI + ".sig"
 }
 
 func GetConnectorPackaging(path string) (*ConnectorPackaging, error) {
@@ -0,0 +1,76 @@
+package signature
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy configures the keys that connector tarballs are verified with, per
+// namespace. It is read from a YAML file like:
+//
+//	namespaces:
+//	  hasura:
+//	    required: true
+//	    keys:
+//	      - keys/hasura.pub
+//
+// Key paths are relative to the policy file.
+type Policy struct {
+	Namespaces map[string]NamespacePolicy `yaml:"namespaces"`
+}
+
+type NamespacePolicy struct {
+	// Required refuses unsigned artefacts in the namespace.
+	Required bool     `yaml:"required"`
+	KeyFiles []string `yaml:"keys"`
+
+	keys []crypto.PublicKey
+}
+
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("error parsing signature policy %s: %w", path, err)
+	}
+
+	for namespace, np := range policy.Namespaces {
+		if np.Required && len(np.KeyFiles) == 0 {
+			return nil, fmt.Errorf("namespace %s requires signatures but has no keys", namespace)
+		}
+		for _, keyFile := range np.KeyFiles {
+			if !filepath.IsAbs(keyFile) {
+				keyFile = filepath.Join(filepath.Dir(path), keyFile)
+			}
+			keyData, err := os.ReadFile(keyFile)
+			if err != nil {
+				return nil, err
+			}
+			key, err := ParsePublicKey(keyData)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", keyFile, err)
+			}
+			np.keys = append(np.keys, key)
+		}
+		policy.Namespaces[namespace] = np
+	}
+	return &policy, nil
+}
+
+// Keys returns the keys of the namespace and whether its artefacts must be
+// signed.
+func (p *Policy) Keys(namespace string) ([]crypto.PublicKey, bool) {
+	if p == nil {
+		return nil, false
+	}
+	np := p.Namespaces[namespace]
+	return np.keys, np.Required
+}
@@ -0,0 +1,104 @@
+// Package signature verifies and creates detached signatures of artefacts.
+//
+// Signatures are base64 encoded and compatible with cosign blob signatures:
+// ECDSA keys sign the SHA-256 digest of the blob, Ed25519 keys sign the blob
+// itself. Keys are PEM encoded, public keys as PKIX and private keys as PKCS #8.
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+func ParsePublicKey(pemData []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+func ParsePrivateKey(pemData []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// DecodeSignature decodes the content of a detached signature file.
+func DecodeSignature(data []byte) ([]byte, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %w", err)
+	}
+	return sig, nil
+}
+
+// Verify checks sig over message with any of the keys.
+func Verify(keys []crypto.PublicKey, message, sig []byte) error {
+	for _, key := range keys {
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			digest := sha256.Sum256(message)
+			if ecdsa.VerifyASN1(key, digest[:], sig) {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, message, sig) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Sign returns the base64 encoded detached signature of message.
+func Sign(key crypto.Signer, message []byte) ([]byte, error) {
+	var sig []byte
+	var err error
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(message)
+		sig, err = ecdsa.SignASN1(rand.Reader, key, digest[:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, message)
+	default:
+		err = fmt.Errorf("unsupported private key type %T", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), nil
+}
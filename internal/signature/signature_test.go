@@ -0,0 +1,113 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encodeKeys(t *testing.T, priv crypto.Signer) ([]byte, []byte) {
+	t.Helper()
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		Name string
+		Key  crypto.Signer
+	}{
+		{Name: "ECDSA P-256", Key: ecdsaKey},
+		{Name: "Ed25519", Key: ed25519Key},
+	}
+
+	message := []byte("connector-definition.tar.gz content")
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			privPEM, pubPEM := encodeKeys(t, tc.Key)
+			priv, err := ParsePrivateKey(privPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := ParsePublicKey(pubPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sigData, err := Sign(priv, message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := DecodeSignature(sigData)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Verify([]crypto.PublicKey{pub}, message, sig); err != nil {
+				t.Errorf("expected a valid signature, got %v", err)
+			}
+			if err := Verify([]crypto.PublicKey{pub}, []byte("tampered"), sig); err == nil {
+				t.Error("expected the signature of a tampered message to be invalid")
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pubPEM := encodeKeys(t, key)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hasura.pub"), pubPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	policyPath := filepath.Join(dir, "policy.yaml")
+	err = os.WriteFile(policyPath, []byte(`
+namespaces:
+  hasura:
+    required: true
+    keys:
+      - hasura.pub
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := LoadPolicy(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, required := policy.Keys("hasura"); len(keys) != 1 || !required {
+		t.Errorf("expected one required key for hasura, got %d keys, required %v", len(keys), required)
+	}
+	if keys, required := policy.Keys("community"); len(keys) != 0 || required {
+		t.Errorf("expected no keys for an unconfigured namespace, got %d keys, required %v", len(keys), required)
+	}
+}
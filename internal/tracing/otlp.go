@@ -0,0 +1,200 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The OTLP JSON encoding of spans, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusCodeError  = 2
+)
+
+func otlpAttributes(attrs []Attribute) []otlpAttribute {
+	var encoded []otlpAttribute
+	for _, attr := range attrs {
+		var value map[string]any
+		switch v := attr.Value.(type) {
+		case int64:
+			// 64 bit integers are strings in the JSON encoding
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
+
+// MarshalOTLP encodes spans as an OTLP JSON ExportTraceServiceRequest.
+func MarshalOTLP(resource []Attribute, spans []*Span) ([]byte, error) {
+	scope := otlpScopeSpans{
+		Scope: otlpScope{Name: "github.com/hasura/ddn-assets"},
+		Spans: []otlpSpan{},
+	}
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.TraceID(),
+			SpanID:            s.SpanID(),
+			ParentSpanID:      s.ParentSpanID(),
+			Name:              s.name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attrs),
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: otlpStatusCodeError, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	return json.Marshal(otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: otlpAttributes(resource)},
+			ScopeSpans: []otlpScopeSpans{scope},
+		}},
+	})
+}
+
+// OTLPExporter posts spans to an OTLP/HTTP traces endpoint in the JSON
+// encoding.
+type OTLPExporter struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// DefaultExportTimeout bounds an export to an OTLP/HTTP endpoint, it is the
+// default of OTEL_EXPORTER_OTLP_TIMEOUT.
+const DefaultExportTimeout = 10 * time.Second
+
+// NewOTLPExporter exports to the traces endpoint of an OTLP/HTTP collector,
+// endpoint being its base URL as in OTEL_EXPORTER_OTLP_ENDPOINT. An export
+// that takes longer than timeout fails, so that an unreachable collector does
+// not hang the end of a run.
+func NewOTLPExporter(endpoint string, headers map[string]string, timeout time.Duration) *OTLPExporter {
+	return &OTLPExporter{
+		URL:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		Headers: headers,
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (e *OTLPExporter) ExportSpans(resource []Attribute, spans []*Span) error {
+	body, err := MarshalOTLP(resource, spans)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error exporting spans to %s: %w", e.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("error exporting spans to %s: status code %d", e.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// WriterExporter writes spans as a line of OTLP JSON, the format of the file
+// exporter of the OpenTelemetry collector.
+type WriterExporter struct {
+	W io.Writer
+}
+
+func (e *WriterExporter) ExportSpans(resource []Attribute, spans []*Span) error {
+	data, err := MarshalOTLP(resource, spans)
+	if err != nil {
+		return err
+	}
+	_, err = e.W.Write(append(data, '\n'))
+	return err
+}
+
+// ParseHeaders parses headers in the key1=value1,key2=value2 format of
+// OTEL_EXPORTER_OTLP_HEADERS.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q, expected key=value", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %q: %w", pair, err)
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	return headers, nil
+}
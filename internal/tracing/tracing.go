@@ -0,0 +1,168 @@
+// Package tracing records OpenTelemetry spans of a run and exports them in the
+// OTLP JSON encoding, either to an OTLP/HTTP endpoint or to a file.
+//
+// It stands in for the OpenTelemetry SDK, which is not a dependency, and only
+// covers what a single generate run needs: every span is kept in memory until
+// Shutdown exports them all in one request, without batching nor sampling, so
+// a run that crashes exports nothing.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Attribute is a key and a string, int64 or bool value attached to a span.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Exporter sends the spans of a run somewhere.
+type Exporter interface {
+	ExportSpans(resource []Attribute, spans []*Span) error
+}
+
+// Tracer records the spans of a single trace, rooted at a span covering the
+// whole run. A nil Tracer records nothing, so that callers do not have to
+// check whether tracing is enabled.
+type Tracer struct {
+	resource  []Attribute
+	exporters []Exporter
+	root      *Span
+
+	mu    sync.Mutex
+	ended []*Span
+}
+
+// NewTracer starts the trace of a run with a root span named name. The spans
+// are exported on Shutdown.
+func NewTracer(name string, resource []Attribute, exporters ...Exporter) *Tracer {
+	t := &Tracer{resource: resource, exporters: exporters}
+	t.root = t.Start(nil, name)
+	return t
+}
+
+// Start starts a span, as a child of the root span when parent is nil.
+func (t *Tracer) Start(parent *Span, name string, attrs ...Attribute) *Span {
+	if t == nil {
+		return nil
+	}
+	if parent == nil {
+		parent = t.root
+	}
+
+	s := &Span{
+		tracer: t,
+		name:   name,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+	rand.Read(s.id[:])
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.id
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	return s
+}
+
+// Shutdown ends the root span, with err telling whether the run failed, and
+// exports every ended span.
+func (t *Tracer) Shutdown(err error) error {
+	if t == nil {
+		return nil
+	}
+	t.root.End(err)
+
+	t.mu.Lock()
+	spans := t.ended
+	t.ended = nil
+	t.mu.Unlock()
+
+	var errs []error
+	for _, exporter := range t.exporters {
+		errs = append(errs, exporter.ExportSpans(t.resource, spans))
+	}
+	return errors.Join(errs...)
+}
+
+// Span is an operation of the trace. Its methods do nothing on a nil Span.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	id       [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []Attribute
+	err   error
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// End ends the span, with the error of the operation when it failed.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.ended = append(s.tracer.ended, s)
+}
+
+func (s *Span) Name() string {
+	return s.name
+}
+
+func (s *Span) TraceID() string {
+	return hex.EncodeToString(s.traceID[:])
+}
+
+func (s *Span) SpanID() string {
+	return hex.EncodeToString(s.id[:])
+}
+
+// ParentSpanID is empty for the root span.
+func (s *Span) ParentSpanID() string {
+	if s.parentID == [8]byte{} {
+		return ""
+	}
+	return hex.EncodeToString(s.parentID[:])
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracer(t *testing.T) {
+	var out bytes.Buffer
+	tracer := NewTracer("generate", []Attribute{String("service.name", "ddn-assets")}, &WriterExporter{W: &out})
+
+	stage := tracer.Start(nil, "download", Int64("versions", 1))
+	version := tracer.Start(stage, "download hasura/foo v1")
+	download := tracer.Start(version, "download connector-definition.tar.gz")
+	download.SetAttributes(String("source", "network"), Bool("cached", false))
+	download.End(errors.New("boom"))
+	download.End(nil)
+	version.End(nil)
+	stage.End(nil)
+
+	if err := tracer.Shutdown(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var traces otlpTraces
+	if err := json.Unmarshal(out.Bytes(), &traces); err != nil {
+		t.Fatal(err)
+	}
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	byName := make(map[string]otlpSpan)
+	for _, span := range spans {
+		if span.TraceID != spans[0].TraceID {
+			t.Errorf("span %s is not in the trace %s", span.Name, spans[0].TraceID)
+		}
+		byName[span.Name] = span
+	}
+
+	parents := map[string]string{
+		"download":                             "generate",
+		"download hasura/foo v1":               "download",
+		"download connector-definition.tar.gz": "download hasura/foo v1",
+	}
+	for child, parent := range parents {
+		if byName[child].ParentSpanID != byName[parent].SpanID {
+			t.Errorf("expected %s to be a child of %s", child, parent)
+		}
+	}
+	if byName["generate"].ParentSpanID != "" {
+		t.Errorf("expected the root span to have no parent")
+	}
+
+	failed := byName["download connector-definition.tar.gz"]
+	if failed.Status.Code != otlpStatusCodeError || failed.Status.Message != "boom" {
+		t.Errorf("expected the error of the first End, got %+v", failed.Status)
+	}
+	if len(failed.Attributes) != 2 || failed.Attributes[1].Value["boolValue"] != false {
+		t.Errorf("unexpected attributes %+v", failed.Attributes)
+	}
+	if byName["download"].Attributes[0].Value["intValue"] != "1" {
+		t.Errorf("expected int64 attributes as strings, got %+v", byName["download"].Attributes)
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	span := tracer.Start(nil, "download")
+	span.SetAttributes(String("source", "cache"))
+	span.End(nil)
+	if err := tracer.Shutdown(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected export %s %v", r.URL.Path, r.Header)
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	headers, err := ParseHeaders("Authorization=Bearer%20token, ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := NewTracer("generate", nil, NewOTLPExporter(server.URL+"/", headers, DefaultExportTimeout))
+	if err := tracer.Shutdown(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var traces otlpTraces
+	if err := json.Unmarshal(body, &traces); err != nil {
+		t.Fatal(err)
+	}
+	if spans := traces.ResourceSpans[0].ScopeSpans[0].Spans; len(spans) != 1 || spans[0].Name != "generate" {
+		t.Errorf("unexpected exported spans %+v", spans)
+	}
+
+	if _, err := ParseHeaders("Authorization"); err == nil {
+		t.Errorf("expected an error for a header without a value")
+	}
+}
+
+func TestOTLPExporterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tracer := NewTracer("generate", nil, NewOTLPExporter(server.URL, nil, 50*time.Millisecond))
+	start := time.Now()
+	if err := tracer.Shutdown(nil); err == nil {
+		t.Fatal("expected the export to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the export to give up after its timeout, took %s", elapsed)
+	}
+}